@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/shiiyan/my-stringsvc/pb"
+)
+
+// grpcServer serves StringService over gRPC next to HTTP.
+type grpcServer struct {
+	pb.UnimplementedStringServiceServer
+	uppercase grpctransport.Handler
+	count     grpctransport.Handler
+}
+
+func newGRPCServer(svc StringService) pb.StringServiceServer {
+	return &grpcServer{
+		uppercase: grpctransport.NewServer(
+			makeUppercaseEndpoint(svc),
+			decodeGRPCUppercaseRequest,
+			encodeGRPCUppercaseResponse,
+		),
+		count: grpctransport.NewServer(
+			makeCountEndpoint(svc),
+			decodeGRPCCountRequest,
+			encodeGRPCCountResponse,
+		),
+	}
+}
+
+func (s *grpcServer) Uppercase(ctx context.Context, req *pb.UppercaseRequest) (*pb.UppercaseReply, error) {
+	_, rep, err := s.uppercase.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.UppercaseReply), nil
+}
+
+func (s *grpcServer) Count(ctx context.Context, req *pb.CountRequest) (*pb.CountReply, error) {
+	_, rep, err := s.count.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*pb.CountReply), nil
+}
+
+func decodeGRPCUppercaseRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.UppercaseRequest)
+	return uppercaseRequest{S: req.S}, nil
+}
+
+func encodeGRPCUppercaseResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(uppercaseResponse)
+	if resp.Err != "" {
+		return nil, grpcError(resp.Err)
+	}
+	return &pb.UppercaseReply{V: resp.V}, nil
+}
+
+func decodeGRPCCountRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.CountRequest)
+	return countRequest{S: req.S}, nil
+}
+
+func encodeGRPCCountResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(countResponse)
+	return &pb.CountReply{V: int64(resp.V)}, nil
+}
+
+// grpcError answers the error of a response as a status. ErrEmpty is
+// InvalidArgument with the ErrorInfo my-stringsvc3 sends for it, so its proxy
+// takes it for its own ErrEmpty.
+func grpcError(message string) error {
+	if message != ErrEmpty.Error() {
+		return status.Error(codes.Internal, message)
+	}
+	st := status.New(codes.InvalidArgument, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: "empty_string", Domain: "stringsvc"}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
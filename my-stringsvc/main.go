@@ -4,14 +4,24 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
+	"google.golang.org/grpc"
 
 	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/shiiyan/my-stringsvc/pb"
 )
 
 type StringService interface {
@@ -73,6 +83,16 @@ func makeCountEndpoint(svc StringService) endpoint.Endpoint {
 }
 
 func main() {
+	var (
+		listen          = flag.String("listen", ":8080", "HTTP listen address")
+		grpcListen      = flag.String("grpc-listen", "", "Optional gRPC listen address, e.g. :8082")
+		readTimeout     = flag.Duration("read-timeout", 5*time.Second, "Time allowed to read a request, body included; 0 is unlimited")
+		writeTimeout    = flag.Duration("write-timeout", 10*time.Second, "Time allowed to write a response; 0 is unlimited")
+		idleTimeout     = flag.Duration("idle-timeout", 60*time.Second, "How long a keep-alive connection may wait for its next request")
+		shutdownTimeout = flag.Duration("shutdown-timeout", 15*time.Second, "How long a shutdown waits for requests in flight")
+	)
+	flag.Parse()
+
 	svc := stringService{}
 
 	uppercaseHandler := httptransport.NewServer(
@@ -88,9 +108,106 @@ func main() {
 
 	http.Handle("/uppercase", uppercaseHandler)
 	http.Handle("/count", countHandler)
+	http.HandleFunc("/healthz", healthz)
+	http.HandleFunc("/readyz", readyz)
+
+	srv := &http.Server{
+		Addr:         *listen,
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
+	}
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterStringServiceServer(grpcServer, newGRPCServer(svc))
+
+	fmt.Println("Server starting on http://localhost" + *listen)
+	if *grpcListen != "" {
+		fmt.Println("gRPC server starting on " + *grpcListen)
+	}
+	if err := serve(srv, *grpcListen, grpcServer, *shutdownTimeout); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Server stopped")
+}
 
-	fmt.Println("Server starting on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+// serve runs srv, and grpcServer on grpcAddr unless that is empty, until
+// SIGINT or SIGTERM, then stops taking new requests and waits up to timeout
+// for those in flight. Cutting the remaining ones is an error.
+func serve(srv *http.Server, grpcAddr string, grpcServer *grpc.Server, timeout time.Duration) (err error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 2)
+	if grpcAddr == "" {
+		grpcServer = nil
+	} else {
+		ln, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			// Serve returns nil once stopped
+			if err := grpcServer.Serve(ln); err != nil {
+				errc <- fmt.Errorf("gRPC: %w", err)
+			}
+		}()
+	}
+	go func() { errc <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		// a second signal kills the process without waiting for the drain
+		stop()
+	}
+
+	draining.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		defer func() {
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				grpcServer.Stop()
+				if err == nil {
+					err = fmt.Errorf("gRPC: %w", ctx.Err())
+				}
+			}
+		}()
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// draining is set once serve begins shutting down.
+var draining atomic.Bool
+
+// readyz fails once draining, so load balancers stop sending requests while
+// those in flight finish.
+func readyz(w http.ResponseWriter, _ *http.Request) {
+	if draining.Load() {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
 }
 
 func decodeUppercaseRequest(_ context.Context, r *http.Request) (interface{}, error) {
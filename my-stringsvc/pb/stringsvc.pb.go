@@ -0,0 +1,271 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: stringsvc.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type UppercaseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	S             string                 `protobuf:"bytes,1,opt,name=s,proto3" json:"s,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UppercaseRequest) Reset() {
+	*x = UppercaseRequest{}
+	mi := &file_stringsvc_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UppercaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UppercaseRequest) ProtoMessage() {}
+
+func (x *UppercaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stringsvc_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UppercaseRequest.ProtoReflect.Descriptor instead.
+func (*UppercaseRequest) Descriptor() ([]byte, []int) {
+	return file_stringsvc_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *UppercaseRequest) GetS() string {
+	if x != nil {
+		return x.S
+	}
+	return ""
+}
+
+type UppercaseReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	V             string                 `protobuf:"bytes,1,opt,name=v,proto3" json:"v,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UppercaseReply) Reset() {
+	*x = UppercaseReply{}
+	mi := &file_stringsvc_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UppercaseReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UppercaseReply) ProtoMessage() {}
+
+func (x *UppercaseReply) ProtoReflect() protoreflect.Message {
+	mi := &file_stringsvc_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UppercaseReply.ProtoReflect.Descriptor instead.
+func (*UppercaseReply) Descriptor() ([]byte, []int) {
+	return file_stringsvc_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *UppercaseReply) GetV() string {
+	if x != nil {
+		return x.V
+	}
+	return ""
+}
+
+type CountRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	S             string                 `protobuf:"bytes,1,opt,name=s,proto3" json:"s,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountRequest) Reset() {
+	*x = CountRequest{}
+	mi := &file_stringsvc_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountRequest) ProtoMessage() {}
+
+func (x *CountRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stringsvc_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountRequest.ProtoReflect.Descriptor instead.
+func (*CountRequest) Descriptor() ([]byte, []int) {
+	return file_stringsvc_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CountRequest) GetS() string {
+	if x != nil {
+		return x.S
+	}
+	return ""
+}
+
+type CountReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	V             int64                  `protobuf:"varint,1,opt,name=v,proto3" json:"v,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountReply) Reset() {
+	*x = CountReply{}
+	mi := &file_stringsvc_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountReply) ProtoMessage() {}
+
+func (x *CountReply) ProtoReflect() protoreflect.Message {
+	mi := &file_stringsvc_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountReply.ProtoReflect.Descriptor instead.
+func (*CountReply) Descriptor() ([]byte, []int) {
+	return file_stringsvc_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CountReply) GetV() int64 {
+	if x != nil {
+		return x.V
+	}
+	return 0
+}
+
+var File_stringsvc_proto protoreflect.FileDescriptor
+
+const file_stringsvc_proto_rawDesc = "" +
+	"\n" +
+	"\x0fstringsvc.proto\x12\x02pb\" \n" +
+	"\x10UppercaseRequest\x12\f\n" +
+	"\x01s\x18\x01 \x01(\tR\x01s\"\x1e\n" +
+	"\x0eUppercaseReply\x12\f\n" +
+	"\x01v\x18\x01 \x01(\tR\x01v\"\x1c\n" +
+	"\fCountRequest\x12\f\n" +
+	"\x01s\x18\x01 \x01(\tR\x01s\"\x1a\n" +
+	"\n" +
+	"CountReply\x12\f\n" +
+	"\x01v\x18\x01 \x01(\x03R\x01v2u\n" +
+	"\rStringService\x127\n" +
+	"\tUppercase\x12\x14.pb.UppercaseRequest\x1a\x12.pb.UppercaseReply\"\x00\x12+\n" +
+	"\x05Count\x12\x10.pb.CountRequest\x1a\x0e.pb.CountReply\"\x00B$Z\"github.com/shiiyan/my-stringsvc/pbb\x06proto3"
+
+var (
+	file_stringsvc_proto_rawDescOnce sync.Once
+	file_stringsvc_proto_rawDescData []byte
+)
+
+func file_stringsvc_proto_rawDescGZIP() []byte {
+	file_stringsvc_proto_rawDescOnce.Do(func() {
+		file_stringsvc_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_stringsvc_proto_rawDesc), len(file_stringsvc_proto_rawDesc)))
+	})
+	return file_stringsvc_proto_rawDescData
+}
+
+var file_stringsvc_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_stringsvc_proto_goTypes = []any{
+	(*UppercaseRequest)(nil), // 0: pb.UppercaseRequest
+	(*UppercaseReply)(nil),   // 1: pb.UppercaseReply
+	(*CountRequest)(nil),     // 2: pb.CountRequest
+	(*CountReply)(nil),       // 3: pb.CountReply
+}
+var file_stringsvc_proto_depIdxs = []int32{
+	0, // 0: pb.StringService.Uppercase:input_type -> pb.UppercaseRequest
+	2, // 1: pb.StringService.Count:input_type -> pb.CountRequest
+	1, // 2: pb.StringService.Uppercase:output_type -> pb.UppercaseReply
+	3, // 3: pb.StringService.Count:output_type -> pb.CountReply
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_stringsvc_proto_init() }
+func file_stringsvc_proto_init() {
+	if File_stringsvc_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_stringsvc_proto_rawDesc), len(file_stringsvc_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_stringsvc_proto_goTypes,
+		DependencyIndexes: file_stringsvc_proto_depIdxs,
+		MessageInfos:      file_stringsvc_proto_msgTypes,
+	}.Build()
+	File_stringsvc_proto = out.File
+	file_stringsvc_proto_goTypes = nil
+	file_stringsvc_proto_depIdxs = nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,11 +10,20 @@ import (
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/shiiyan/learn-go/my-stringsvc2/pb"
 )
 
 func main() {
+	listen := flag.String("listen", ":8080", "HTTP listen address")
+	grpcListen := flag.String("grpc-listen", "", "Optional gRPC listen address, e.g. :8082")
+	srvCfg := defaultServerConfig()
+	srvCfg.registerFlags(flag.CommandLine)
+	flag.Parse()
+
 	logger := log.NewLogfmtLogger(os.Stderr)
 
 	fieldKeys := []string{"method", "error"}
@@ -55,7 +65,19 @@ func main() {
 	http.Handle("/uppercase", uppercaseHandler)
 	http.Handle("/count", countHandler)
 	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/healthz", healthz)
+	http.HandleFunc("/readyz", readyz)
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterStringServiceServer(grpcServer, newGRPCServer(svc))
 
-	fmt.Println("Server starting on http://localhost:8080")
-	logger.Log(http.ListenAndServe(":8080", nil))
+	fmt.Println("Server starting on http://localhost" + *listen)
+	if *grpcListen != "" {
+		fmt.Println("gRPC server starting on " + *grpcListen)
+	}
+	if err := srvCfg.serve(*listen, nil, *grpcListen, grpcServer); err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	logger.Log("shutdown", "complete")
 }
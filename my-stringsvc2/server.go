@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// serverConfig bounds how long connections may take and how long a shutdown
+// waits for requests in flight.
+type serverConfig struct {
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+}
+
+func defaultServerConfig() serverConfig {
+	return serverConfig{
+		ReadTimeout:     5 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		IdleTimeout:     60 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+	}
+}
+
+func (c *serverConfig) registerFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "Time allowed to read a request, body included; 0 is unlimited")
+	fs.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "Time allowed to write a response; 0 is unlimited")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "How long a keep-alive connection may wait for its next request")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "How long a shutdown waits for requests in flight")
+}
+
+// serve runs an HTTP server on addr, and grpcServer on grpcAddr unless that is
+// empty, until SIGINT or SIGTERM, then stops taking new requests and waits up
+// to ShutdownTimeout for those in flight.
+func (c serverConfig) serve(addr string, h http.Handler, grpcAddr string, grpcServer *grpc.Server) error {
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      h,
+		ReadTimeout:  c.ReadTimeout,
+		WriteTimeout: c.WriteTimeout,
+		IdleTimeout:  c.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 2)
+	if grpcAddr == "" {
+		grpcServer = nil
+	} else {
+		ln, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			// Serve returns nil once stopped
+			if err := grpcServer.Serve(ln); err != nil {
+				errc <- fmt.Errorf("gRPC: %w", err)
+			}
+		}()
+	}
+	go func() { errc <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		// a second signal kills the process without waiting for the drain
+		stop()
+	}
+
+	draining.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancel()
+	if err := shutdown(ctx, srv, grpcServer); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// shutdown stops both servers taking new requests and waits for those in
+// flight until ctx is done, when the remaining connections are cut and an
+// error is returned. grpcServer may be nil.
+func shutdown(ctx context.Context, srv *http.Server, grpcServer *grpc.Server) (err error) {
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		defer func() {
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				grpcServer.Stop()
+				if err == nil {
+					err = fmt.Errorf("gRPC: %w", ctx.Err())
+				}
+			}
+		}()
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+		return err
+	}
+	return nil
+}
+
+// draining is set once serve begins shutting down.
+var draining atomic.Bool
+
+// healthz reports the process is alive.
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// readyz fails once draining, so load balancers stop sending requests while
+// those in flight finish.
+func readyz(w http.ResponseWriter, _ *http.Request) {
+	if draining.Load() {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// proxyConfig tunes how the proxy treats its upstreams. Rate limits and
+// breakers apply per upstream instance; attempts, budget and backoff apply
+// per proxied request.
+type proxyConfig struct {
+	QPS   float64 `yaml:"qps"`
+	Burst int     `yaml:"burst"`
+
+	MaxAttempts    int           `yaml:"max_attempts"`
+	RetryBudget    time.Duration `yaml:"retry_budget"`
+	AttemptTimeout time.Duration `yaml:"attempt_timeout"`
+	Backoff        time.Duration `yaml:"backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+
+	Breaker breakerConfig `yaml:"breaker"`
+}
+
+type breakerConfig struct {
+	// ConsecutiveFailures trips the breaker.
+	ConsecutiveFailures uint32 `yaml:"consecutive_failures"`
+	// OpenTimeout is how long the breaker stays open before half-opening.
+	OpenTimeout time.Duration `yaml:"open_timeout"`
+	// HalfOpenRequests may pass while half-open.
+	HalfOpenRequests uint32 `yaml:"half_open_requests"`
+	// Interval clears the failure counts while closed; 0 never clears them.
+	Interval time.Duration `yaml:"interval"`
+}
+
+func defaultProxyConfig() proxyConfig {
+	return proxyConfig{
+		QPS:            10,
+		Burst:          10,
+		MaxAttempts:    3,
+		RetryBudget:    250 * time.Millisecond,
+		AttemptTimeout: 100 * time.Millisecond,
+		Backoff:        10 * time.Millisecond,
+		MaxBackoff:     100 * time.Millisecond,
+		Breaker: breakerConfig{
+			ConsecutiveFailures: 5,
+			OpenTimeout:         60 * time.Second,
+			HalfOpenRequests:    1,
+		},
+	}
+}
+
+func (c *proxyConfig) registerFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&c.QPS, "proxy-qps", c.QPS, "Requests per second allowed to each upstream")
+	fs.IntVar(&c.Burst, "proxy-burst", c.Burst, "Requests each upstream may receive in a burst")
+	fs.IntVar(&c.MaxAttempts, "proxy-max-attempts", c.MaxAttempts, "Attempts per proxied request, across upstreams")
+	fs.DurationVar(&c.RetryBudget, "proxy-retry-budget", c.RetryBudget, "Total time allowed for all attempts of a proxied request")
+	fs.DurationVar(&c.AttemptTimeout, "proxy-attempt-timeout", c.AttemptTimeout, "Time allowed for one attempt")
+	fs.DurationVar(&c.Backoff, "proxy-backoff", c.Backoff, "Wait before the first retry, doubled for each further retry")
+	fs.DurationVar(&c.MaxBackoff, "proxy-max-backoff", c.MaxBackoff, "Longest wait between retries")
+	fs.Func("proxy-breaker-failures", "Consecutive failures that open an upstream's breaker (default 5)", uint32Flag(&c.Breaker.ConsecutiveFailures))
+	fs.DurationVar(&c.Breaker.OpenTimeout, "proxy-breaker-open-timeout", c.Breaker.OpenTimeout, "How long an open breaker waits before letting a probe through")
+	fs.Func("proxy-breaker-half-open", "Requests let through a half-open breaker (default 1)", uint32Flag(&c.Breaker.HalfOpenRequests))
+	fs.DurationVar(&c.Breaker.Interval, "proxy-breaker-interval", c.Breaker.Interval, "How often a closed breaker forgets failures; 0 never does")
+}
+
+func uint32Flag(p *uint32) func(string) error {
+	return func(s string) error {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return err
+		}
+		*p = uint32(n)
+		return nil
+	}
+}
+
+// load reads a YAML file over c; fields missing from the file keep
+// their current values. Durations are written like "250ms".
+func (c *proxyConfig) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return yaml.NewDecoder(f).Decode(c)
+}
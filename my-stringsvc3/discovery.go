@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/dnssrv"
+	"github.com/go-kit/log"
+)
+
+// instanceCache is a minimal sd.Instancer fed through update. go-kit keeps its
+// own in sd/internal, out of reach, so the discovery sources below share this.
+type instanceCache struct {
+	mu    sync.Mutex
+	state sd.Event
+	subs  map[chan<- sd.Event]struct{}
+}
+
+func newInstanceCache() *instanceCache {
+	return &instanceCache{subs: map[chan<- sd.Event]struct{}{}}
+}
+
+func (c *instanceCache) update(event sd.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sort.Strings(event.Instances)
+	if reflect.DeepEqual(c.state, event) {
+		return
+	}
+	c.state = event
+	for ch := range c.subs {
+		ch <- copyEvent(event)
+	}
+}
+
+func (c *instanceCache) current() sd.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyEvent(c.state)
+}
+
+func (c *instanceCache) Register(ch chan<- sd.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subs[ch] = struct{}{}
+	ch <- copyEvent(c.state)
+}
+
+func (c *instanceCache) Deregister(ch chan<- sd.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.subs, ch)
+}
+
+func (c *instanceCache) Stop() {}
+
+func copyEvent(e sd.Event) sd.Event {
+	if e.Instances == nil {
+		return e
+	}
+	return sd.Event{Instances: append([]string(nil), e.Instances...), Err: e.Err}
+}
+
+// pollingInstancer refreshes its instances from fetch every interval. A failed
+// fetch is reported but keeps the last good instances, like dnssrv does.
+type pollingInstancer struct {
+	*instanceCache
+	quit chan struct{}
+}
+
+func newPollingInstancer(name string, interval time.Duration, fetch func() ([]string, error), logger log.Logger) *pollingInstancer {
+	p := &pollingInstancer{instanceCache: newInstanceCache(), quit: make(chan struct{})}
+
+	refresh := func() {
+		instances, err := fetch()
+		if err != nil {
+			logger.Log("source", name, "err", err)
+			if last := p.current(); last.Instances != nil {
+				return
+			}
+			p.update(sd.Event{Err: err})
+			return
+		}
+		p.update(sd.Event{Instances: instances})
+	}
+	refresh()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				refresh()
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *pollingInstancer) Stop() {
+	close(p.quit)
+}
+
+// newFileInstancer watches a file listing one instance per line; blank lines
+// and lines starting with # are ignored. Edits are picked up on the next poll.
+func newFileInstancer(path string, interval time.Duration, logger log.Logger) sd.Instancer {
+	return newPollingInstancer(path, interval, func() ([]string, error) {
+		return readInstanceFile(path)
+	}, logger)
+}
+
+func readInstanceFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	instances := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		instances = append(instances, line)
+	}
+	return instances, scanner.Err()
+}
+
+// newDNSInstancer resolves the SRV record name every ttl. A non-empty resolver
+// ("127.0.0.1:53") is queried instead of the system resolver.
+func newDNSInstancer(name, resolver string, ttl time.Duration, logger log.Logger) sd.Instancer {
+	lookup := net.LookupSRV
+	if resolver != "" {
+		r := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, resolver)
+			},
+		}
+		lookup = func(service, proto, name string) (string, []*net.SRV, error) {
+			return r.LookupSRV(context.Background(), service, proto, name)
+		}
+	}
+	return dnssrv.NewInstancerDetailed(name, time.NewTicker(ttl), lookup, log.With(logger, "source", "dns"))
+}
+
+// healthChecker sits between an Instancer and the endpointer, probing every
+// discovered instance each interval and passing on only the healthy ones, so
+// dead upstreams leave the balancer before their discovery source notices.
+// Probes run in parallel off the event loop, so a slow instance neither delays
+// the others nor holds up events from the source.
+type healthChecker struct {
+	*instanceCache
+	source  sd.Instancer
+	probe   func(ctx context.Context, instance string) error
+	logger  log.Logger
+	events  chan sd.Event
+	results chan map[string]error
+	quit    chan struct{}
+}
+
+// newHealthChecker passes on the instances of source for which probe, given
+// timeout, succeeded in the last round; tcpProbe only checks they accept
+// connections.
+func newHealthChecker(source sd.Instancer, probe func(ctx context.Context, instance string) error, interval, timeout time.Duration, logger log.Logger) *healthChecker {
+	h := &healthChecker{
+		instanceCache: newInstanceCache(),
+		source:        source,
+		probe:         probe,
+		logger:        log.With(logger, "source", "health"),
+		events:        make(chan sd.Event, 1),
+		results:       make(chan map[string]error, 1),
+		quit:          make(chan struct{}),
+	}
+	go h.loop(interval, timeout)
+	source.Register(h.events)
+	return h
+}
+
+func (h *healthChecker) loop(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var discovered sd.Event
+	unhealthy := map[string]bool{}
+	probing := false
+	publish := func() {
+		if discovered.Err != nil {
+			h.update(discovered)
+			return
+		}
+		healthy := []string{}
+		for _, instance := range discovered.Instances {
+			if !unhealthy[instance] {
+				healthy = append(healthy, instance)
+			}
+		}
+		h.update(sd.Event{Instances: healthy})
+	}
+
+	for {
+		select {
+		case discovered = <-h.events:
+			publish()
+		case <-ticker.C:
+			// a round outlasting the interval skips ticks rather than piling up
+			if probing {
+				continue
+			}
+			probing = true
+			go h.probeAll(copyEvent(discovered).Instances, timeout)
+		case probed := <-h.results:
+			probing = false
+			failed := make(map[string]bool, len(probed))
+			for instance, err := range probed {
+				if (err != nil) != unhealthy[instance] {
+					h.logger.Log("instance", instance, "healthy", err == nil, "err", err)
+				}
+				failed[instance] = err != nil
+			}
+			unhealthy = failed
+			publish()
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+// probeAll probes instances concurrently, each within timeout, and sends the
+// outcome to results, which is buffered for the one round in flight.
+func (h *healthChecker) probeAll(instances []string, timeout time.Duration) {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		probed = make(map[string]error, len(instances))
+	)
+	for _, instance := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			err := h.probe(ctx, instance)
+			mu.Lock()
+			probed[instance] = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	h.results <- probed
+}
+
+func (h *healthChecker) Stop() {
+	h.source.Deregister(h.events)
+	close(h.quit)
+}
+
+func tcpProbe(ctx context.Context, instance string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", hostPort(instance))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// hostPort strips the scheme and path that -proxy style URLs may carry.
+func hostPort(instance string) string {
+	if i := strings.Index(instance, "://"); i >= 0 {
+		instance = instance[i+3:]
+	}
+	if i := strings.Index(instance, "/"); i >= 0 {
+		instance = instance[:i]
+	}
+	return instance
+}
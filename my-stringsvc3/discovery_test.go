@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/log"
+)
+
+// awaitInstances reads events from ch until one lists want or the wait runs out.
+func awaitInstances(t *testing.T, ch <-chan sd.Event, want []string, wait time.Duration) {
+	t.Helper()
+	timeout := time.After(wait)
+	var last sd.Event
+	for {
+		select {
+		case last = <-ch:
+			if last.Err == nil && slices.Equal(last.Instances, want) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Expected instances %v, got %+v", want, last)
+		}
+	}
+}
+
+func TestHealthChecker(t *testing.T) {
+	source := newInstanceCache()
+	source.update(sd.Event{Instances: []string{"a:1", "b:1", "c:1"}})
+	probe := func(ctx context.Context, instance string) error {
+		switch instance {
+		case "b:1":
+			return errors.New("connection refused")
+		case "c:1":
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+	h := newHealthChecker(source, probe, 10*time.Millisecond, 200*time.Millisecond, log.NewNopLogger())
+	defer h.Stop()
+	events := make(chan sd.Event, 16)
+	h.Register(events)
+	defer h.Deregister(events)
+
+	t.Run("passes on new instances while a probe hangs", func(t *testing.T) {
+		source.update(sd.Event{Instances: []string{"a:1", "b:1", "c:1", "d:1"}})
+		awaitInstances(t, events, []string{"a:1", "b:1", "c:1", "d:1"}, 100*time.Millisecond)
+	})
+
+	t.Run("drops failed and timed out instances", func(t *testing.T) {
+		awaitInstances(t, events, []string{"a:1", "d:1"}, time.Second)
+	})
+}
+
+func TestFileInstancer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances")
+	os.WriteFile(path, []byte("# upstreams\nb:8080\n\na:8080\n"), 0o644)
+
+	instancer := newFileInstancer(path, 10*time.Millisecond, log.NewNopLogger())
+	defer instancer.(*pollingInstancer).Stop()
+	events := make(chan sd.Event, 16)
+	instancer.Register(events)
+	defer instancer.Deregister(events)
+
+	awaitInstances(t, events, []string{"a:8080", "b:8080"}, time.Second)
+
+	os.WriteFile(path, []byte("c:8080\n"), 0o644)
+	awaitInstances(t, events, []string{"c:8080"}, time.Second)
+
+	// a missing file keeps the last good instances
+	os.Remove(path)
+	time.Sleep(50 * time.Millisecond)
+	if got := instancer.(*pollingInstancer).current(); !reflect.DeepEqual(got.Instances, []string{"c:8080"}) || got.Err != nil {
+		t.Errorf("Expected [c:8080] to be kept, got %+v", got)
+	}
+}
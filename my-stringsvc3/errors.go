@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/ratelimit"
+	"github.com/go-kit/kit/sd/lb"
+	"github.com/sony/gobreaker"
+)
+
+// errorEnvelope is how every error is written on the wire, for whole
+// responses as well as for single batch items and stream lines.
+type errorEnvelope struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorBody struct {
+	Error errorEnvelope `json:"error"`
+}
+
+// errorCodes maps known errors to their wire code and HTTP status. Clients
+// map codes of service errors back to the same error values.
+var errorCodes = []struct {
+	err    error
+	code   string
+	status int
+}{
+	{ErrEmpty, "empty_string", http.StatusUnprocessableEntity},
+	{ErrCountMode, "unknown_count_mode", http.StatusBadRequest},
+	{ErrLocale, "unknown_locale", http.StatusBadRequest},
+	{errBatchTooLarge, "batch_too_large", http.StatusBadRequest},
+	{errUnknownMethod, "unknown_method", http.StatusBadRequest},
+	{gobreaker.ErrOpenState, "breaker_open", http.StatusServiceUnavailable},
+	{gobreaker.ErrTooManyRequests, "breaker_half_open", http.StatusServiceUnavailable},
+	{ratelimit.ErrLimited, "rate_limited", http.StatusServiceUnavailable},
+	{lb.ErrNoEndpoints, "no_upstreams", http.StatusServiceUnavailable},
+	{context.DeadlineExceeded, "deadline_exceeded", http.StatusGatewayTimeout},
+}
+
+// badRequestError wraps failures to decode a request.
+type badRequestError struct {
+	err error
+}
+
+func (e badRequestError) Error() string { return "bad request: " + e.err.Error() }
+
+func (e badRequestError) Unwrap() error { return e.err }
+
+// upstreamError wraps failures to get an answer from any upstream.
+type upstreamError struct {
+	err error
+}
+
+func (e upstreamError) Error() string { return "upstream: " + e.err.Error() }
+
+func (e upstreamError) Unwrap() error { return e.err }
+
+// remoteError is an error answered by an upstream that has no local
+// counterpart in errorCodes. It is always the upstream failing, see
+// decodeError.
+type remoteError struct {
+	errorEnvelope
+	status int
+}
+
+func (e remoteError) Error() string { return e.Message }
+
+func (e remoteError) StatusCode() int { return e.status }
+
+func lookupError(err error) (envelope errorEnvelope, status int) {
+	cause := err
+	// retries report the last attempt's error as Final, without Unwrap
+	var retry lb.RetryError
+	if errors.As(err, &retry) {
+		cause = retry.Final
+	}
+
+	for _, c := range errorCodes {
+		if errors.Is(cause, c.err) {
+			return errorEnvelope{c.code, err.Error()}, c.status
+		}
+	}
+
+	var (
+		bad    badRequestError
+		up     upstreamError
+		remote remoteError
+	)
+	switch {
+	case errors.As(cause, &remote) && errors.As(err, &up):
+		// the upstream failing is a bad gateway here, whatever it said
+		return errorEnvelope{"upstream_error", remote.Message}, http.StatusBadGateway
+	case errors.As(cause, &remote):
+		return remote.errorEnvelope, remote.status
+	case errors.As(err, &bad):
+		return errorEnvelope{"bad_request", err.Error()}, http.StatusBadRequest
+	case errors.As(err, &up):
+		return errorEnvelope{"upstream_error", err.Error()}, http.StatusBadGateway
+	default:
+		return errorEnvelope{"internal", err.Error()}, http.StatusInternalServerError
+	}
+}
+
+// errorFromEnvelope turns a wire error back into the error value it was made
+// from, where there is one.
+func errorFromEnvelope(envelope errorEnvelope, status int) error {
+	for _, c := range errorCodes {
+		if c.code == envelope.Code && c.err.Error() == envelope.Message {
+			return c.err
+		}
+	}
+	for _, c := range errorCodes {
+		if c.code == envelope.Code {
+			return fmt.Errorf("%w: %s", c.err, envelope.Message)
+		}
+	}
+	return remoteError{envelope, status}
+}
+
+// encodeError is the ServerErrorEncoder for every HTTP endpoint.
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	envelope, status := lookupError(err)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody{envelope})
+}
+
+// decodeError reads an error response. Only errors the caller can cause, an
+// envelope with a client error code from errorCodes, come back as the service
+// error. Anything else, such as a 404 from a misrouted upstream or a 429 from
+// an overloaded one, is the upstream failing and is returned as the endpoint
+// error for breakers and retries.
+func decodeError(r *http.Response) (serviceErr error, err error) {
+	var body errorBody
+	raw, _ := io.ReadAll(io.LimitReader(r.Body, maxErrorBody))
+	if json.Unmarshal(raw, &body) != nil || body.Error.Code == "" {
+		message := strings.TrimSpace(string(raw))
+		if message == "" {
+			message = "upstream answered " + r.Status
+		}
+		code := strings.ReplaceAll(strings.ToLower(http.StatusText(r.StatusCode)), " ", "_")
+		return nil, remoteError{errorEnvelope{code, message}, r.StatusCode}
+	}
+
+	e := errorFromEnvelope(body.Error, r.StatusCode)
+	if isClientError(body.Error.Code, r.StatusCode) {
+		return e, nil
+	}
+	return nil, e
+}
+
+// isClientError reports whether an upstream answering code with status
+// blames the request, which makes it a service error rather than a failure.
+func isClientError(code string, status int) bool {
+	if status < http.StatusBadRequest || status >= http.StatusInternalServerError {
+		return false
+	}
+	for _, c := range errorCodes {
+		if c.code == code {
+			return c.status < http.StatusInternalServerError
+		}
+	}
+	return false
+}
+
+// maxErrorBody bounds how much of an error response is read.
+const maxErrorBody = 64 << 10
+
+// failure carries a service error such as ErrEmpty inside a response rather
+// than as the endpoint error, so it doesn't count against breakers or trigger
+// retries. It implements endpoint.Failer.
+type failure struct {
+	Err error `json:"-"`
+}
+
+func (f failure) Failed() error { return f.Err }
+
+func (f *failure) fail(err error) { f.Err = err }
+
+// itemResult is how a batch item or stream line reports its response.
+func itemResult(response interface{}) interface{} {
+	if f, ok := response.(endpoint.Failer); ok && f.Failed() != nil {
+		envelope, _ := lookupError(f.Failed())
+		return errorBody{envelope}
+	}
+	return response
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-kit/kit/ratelimit"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/lb"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLookupError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    string
+		message string
+		status  int
+	}{
+		{"service error", ErrEmpty, "empty_string", "empty string", http.StatusUnprocessableEntity},
+		{"wrapped service error", fmt.Errorf("%w: %q", ErrLocale, "xx"), "unknown_locale", `unknown locale: "xx"`, http.StatusBadRequest},
+		{"decode error", badRequestError{errors.New("EOF")}, "bad_request", "bad request: EOF", http.StatusBadRequest},
+		{"retried breaker", lb.RetryError{Final: gobreaker.ErrOpenState}, "breaker_open", "circuit breaker is open", http.StatusServiceUnavailable},
+		{"deadline", upstreamError{context.DeadlineExceeded}, "deadline_exceeded", "upstream: context deadline exceeded", http.StatusGatewayTimeout},
+		{"remote client error", remoteError{errorEnvelope{"not_found", "no such page"}, http.StatusNotFound}, "not_found", "no such page", http.StatusNotFound},
+		{"remote failure", upstreamError{remoteError{errorEnvelope{"internal", "disk full"}, http.StatusInternalServerError}}, "upstream_error", "disk full", http.StatusBadGateway},
+		{"unreachable upstream", upstreamError{errors.New("connection refused")}, "upstream_error", "upstream: connection refused", http.StatusBadGateway},
+		{"anything else", errors.New("boom"), "internal", "boom", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope, status := lookupError(tt.err)
+			if envelope != (errorEnvelope{tt.code, tt.message}) || status != tt.status {
+				t.Errorf("Expected %s %q with %d, got %+v with %d", tt.code, tt.message, tt.status, envelope, status)
+			}
+		})
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	respond := func(status int, contentType, body string) *http.Response {
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.WriteString(body)
+		return w.Result()
+	}
+
+	t.Run("maps envelopes back to service errors", func(t *testing.T) {
+		serviceErr, err := decodeError(respond(http.StatusUnprocessableEntity, "application/json", `{"error":{"code":"empty_string","message":"empty string"}}`))
+		if !errors.Is(serviceErr, ErrEmpty) || err != nil {
+			t.Errorf("Expected ErrEmpty as service error, got %v, %v", serviceErr, err)
+		}
+	})
+
+	t.Run("takes other client errors as endpoint errors", func(t *testing.T) {
+		serviceErr, err := decodeError(respond(http.StatusNotFound, "text/plain", "404 page not found\n"))
+		var remote remoteError
+		if serviceErr != nil || !errors.As(err, &remote) {
+			t.Fatalf("Expected remoteError as endpoint error, got %v, %v", serviceErr, err)
+		}
+		if remote.Code != "not_found" || remote.Message != "404 page not found" || remote.status != http.StatusNotFound {
+			t.Errorf("Expected not_found from the body, got %+v", remote)
+		}
+
+		_, err = decodeError(respond(http.StatusMethodNotAllowed, "text/plain", ""))
+		if err == nil || err.Error() != "upstream answered 405 Method Not Allowed" {
+			t.Errorf("Expected the status without a body, got %v", err)
+		}
+
+		for _, body := range []string{`{"error":{"code":"too_many_requests","message":"slow down"}}`, `{"error":{"code":"rate_limited","message":"rate limited"}}`} {
+			if serviceErr, err := decodeError(respond(http.StatusTooManyRequests, "application/json", body)); serviceErr != nil || err == nil {
+				t.Errorf("Expected an endpoint error for %s, got %v, %v", body, serviceErr, err)
+			}
+		}
+	})
+
+	t.Run("takes server errors as endpoint errors", func(t *testing.T) {
+		for _, body := range []string{`{"error":{"code":"internal","message":"disk full"}}`, "Bad Gateway"} {
+			serviceErr, err := decodeError(respond(http.StatusBadGateway, "text/plain", body))
+			if serviceErr != nil || err == nil {
+				t.Errorf("Expected an endpoint error for %s, got %v, %v", body, serviceErr, err)
+			}
+		}
+	})
+}
+
+func TestDecodeGRPCError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		code       codes.Code
+		serviceErr error
+		endpoint   error
+	}{
+		{"empty string", ErrEmpty, codes.InvalidArgument, ErrEmpty, nil},
+		{"unknown count mode", fmt.Errorf("%w: %q", ErrCountMode, "lines"), codes.InvalidArgument, ErrCountMode, nil},
+		{"open breaker", upstreamError{gobreaker.ErrOpenState}, codes.Unavailable, nil, gobreaker.ErrOpenState},
+		{"rate limited", ratelimit.ErrLimited, codes.Unavailable, nil, ratelimit.ErrLimited},
+		{"no upstreams", upstreamError{lb.ErrNoEndpoints}, codes.Unavailable, nil, lb.ErrNoEndpoints},
+		{"deadline", upstreamError{context.DeadlineExceeded}, codes.DeadlineExceeded, nil, context.DeadlineExceeded},
+		{"failing upstream", upstreamError{errors.New("connection refused")}, codes.Unavailable, nil, nil},
+		{"anything else", errors.New("boom"), codes.Internal, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := grpcStatus(tt.err)
+			if code := status.Code(st); code != tt.code {
+				t.Errorf("Expected %s, got %s", tt.code, code)
+			}
+
+			serviceErr, err := decodeGRPCError(st)
+			if tt.serviceErr != nil {
+				if !errors.Is(serviceErr, tt.serviceErr) || err != nil {
+					t.Errorf("Expected %v as service error, got %v, %v", tt.serviceErr, serviceErr, err)
+				}
+				return
+			}
+			if serviceErr != nil || err == nil {
+				t.Fatalf("Expected an endpoint error, got %v, %v", serviceErr, err)
+			}
+			if tt.endpoint != nil && !errors.Is(err, tt.endpoint) {
+				t.Errorf("Expected %v as endpoint error, got %v", tt.endpoint, err)
+			}
+		})
+	}
+
+	t.Run("passes on statuses without details", func(t *testing.T) {
+		unreachable := status.Error(codes.Unavailable, "connection refused")
+		if serviceErr, err := decodeGRPCError(unreachable); serviceErr != nil || err != unreachable {
+			t.Errorf("Expected the status as endpoint error, got %v, %v", serviceErr, err)
+		}
+	})
+}
+
+func TestErrorsHTTP(t *testing.T) {
+	t.Run("rejects malformed requests", func(t *testing.T) {
+		front := newFrontend(t, stringService{})
+		resp, err := http.Post(front.URL+"/uppercase", "application/json", strings.NewReader("{"))
+		if err != nil {
+			t.Fatalf("Expected request to succeed, got %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("answers 503 without upstreams", func(t *testing.T) {
+		svc, _ := newTestProxy(t, sd.FixedInstancer{}, defaultRoutes, testProxyConfig(), discardBreakerMetrics())
+		front := newFrontend(t, svc)
+
+		var got errorBody
+		resp := post(t, front.URL+"/uppercase", nil, uppercaseRequest{S: "hello"}, &got)
+		if resp.StatusCode != http.StatusServiceUnavailable || got.Error.Code != "no_upstreams" {
+			t.Errorf("Expected 503 no_upstreams, got %d %+v", resp.StatusCode, got)
+		}
+	})
+
+	t.Run("answers 502 with the message of a failing upstream", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encodeError(r.Context(), errors.New("disk full"), w)
+		}))
+		defer upstream.Close()
+		svc, _ := newTestProxy(t, sd.FixedInstancer{upstream.URL}, defaultRoutes, testProxyConfig(), discardBreakerMetrics())
+		front := newFrontend(t, svc)
+
+		var got errorBody
+		resp := post(t, front.URL+"/uppercase", nil, uppercaseRequest{S: "hello"}, &got)
+		if resp.StatusCode != http.StatusBadGateway || got.Error != (errorEnvelope{"upstream_error", "disk full"}) {
+			t.Errorf("Expected 502 upstream_error disk full, got %d %+v", resp.StatusCode, got)
+		}
+	})
+
+	t.Run("answers 502 for other client errors of the upstream", func(t *testing.T) {
+		for _, status := range []int{http.StatusNotFound, http.StatusTooManyRequests} {
+			var hits atomic.Int32
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				http.Error(w, http.StatusText(status), status)
+			}))
+			defer upstream.Close()
+			cfg := testProxyConfig()
+			cfg.MaxAttempts = 1
+			cfg.Breaker.ConsecutiveFailures = 1
+			svc, _ := newTestProxy(t, sd.FixedInstancer{upstream.URL}, defaultRoutes, cfg, discardBreakerMetrics())
+			front := newFrontend(t, svc)
+
+			var got errorBody
+			resp := post(t, front.URL+"/uppercase", nil, uppercaseRequest{S: "hello"}, &got)
+			if resp.StatusCode != http.StatusBadGateway || got.Error != (errorEnvelope{"upstream_error", http.StatusText(status)}) {
+				t.Errorf("Expected 502 upstream_error for %d, got %d %+v", status, resp.StatusCode, got)
+			}
+
+			got = errorBody{}
+			resp = post(t, front.URL+"/uppercase", nil, uppercaseRequest{S: "hello"}, &got)
+			if resp.StatusCode != http.StatusServiceUnavailable || got.Error.Code != "breaker_open" {
+				t.Errorf("Expected %d to trip the breaker, got %d %+v", status, resp.StatusCode, got)
+			}
+			if hits.Load() != 1 {
+				t.Errorf("Expected the open breaker to spare the upstream, got %d hits", hits.Load())
+			}
+		}
+	})
+}
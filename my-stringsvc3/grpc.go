@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/shiiyan/learn-go/my-stringsvc3/pb"
+)
+
+// grpcServer serves StringService over gRPC next to HTTP.
+type grpcServer struct {
+	pb.UnimplementedStringServiceServer
+	uppercase grpctransport.Handler
+	count     grpctransport.Handler
+}
+
+func newGRPCServer(svc StringService, tracer trace.Tracer) pb.StringServiceServer {
+	server := func(method string) endpoint.Middleware {
+		return traceEndpoint(tracer, "pb.StringService/"+method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.RPCService("pb.StringService"), semconv.RPCMethod(method)),
+		)
+	}
+	return &grpcServer{
+		uppercase: grpctransport.NewServer(
+			server("Uppercase")(makeUppercaseEndpoint(svc)),
+			decodeGRPCUppercaseRequest,
+			encodeGRPCUppercaseResponse,
+			grpctransport.ServerBefore(traceFromGRPC),
+		),
+		count: grpctransport.NewServer(
+			server("Count")(makeCountEndpoint(svc)),
+			decodeGRPCCountRequest,
+			encodeGRPCCountResponse,
+			grpctransport.ServerBefore(traceFromGRPC),
+		),
+	}
+}
+
+func (s *grpcServer) Uppercase(ctx context.Context, req *pb.UppercaseRequest) (*pb.UppercaseReply, error) {
+	_, rep, err := s.uppercase.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcStatus(err)
+	}
+	return rep.(*pb.UppercaseReply), nil
+}
+
+func (s *grpcServer) Count(ctx context.Context, req *pb.CountRequest) (*pb.CountReply, error) {
+	_, rep, err := s.count.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcStatus(err)
+	}
+	return rep.(*pb.CountReply), nil
+}
+
+// dialGRPC connects to an upstream's gRPC port. Connections are lazy, so a
+// dead upstream surfaces as failed calls, like with HTTP.
+func dialGRPC(target string) (*grpc.ClientConn, error) {
+	return grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+func decodeGRPCUppercaseRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.UppercaseRequest)
+	return uppercaseRequest{S: req.S, Locale: req.Locale}, nil
+}
+
+// encodeGRPCUppercaseResponse returns service errors as the error, which the
+// grpcServer methods answer as a status.
+func encodeGRPCUppercaseResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(uppercaseResponse)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return &pb.UppercaseReply{V: resp.V}, nil
+}
+
+func decodeGRPCCountRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.CountRequest)
+	return countRequest{S: req.S, Mode: req.Mode}, nil
+}
+
+func encodeGRPCCountResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(countResponse)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return &pb.CountReply{V: int64(resp.V)}, nil
+}
+
+func encodeGRPCUppercaseRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(uppercaseRequest)
+	return &pb.UppercaseRequest{S: req.S, Locale: req.Locale}, nil
+}
+
+func decodeGRPCUppercaseResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.UppercaseReply)
+	return uppercaseResponse{V: reply.V}, nil
+}
+
+func encodeGRPCCountRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(countRequest)
+	return &pb.CountRequest{S: req.S, Mode: req.Mode}, nil
+}
+
+func decodeGRPCCountResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.CountReply)
+	return countResponse{V: int(reply.V)}, nil
+}
+
+// grpcErrorDomain names this service in the ErrorInfo of error statuses.
+const grpcErrorDomain = "stringsvc"
+
+// grpcStatus answers err with the gRPC code matching its HTTP status and the
+// envelope code as the ErrorInfo reason.
+func grpcStatus(err error) error {
+	envelope, httpStatus := lookupError(err)
+	st := status.New(grpcCode(httpStatus), envelope.Message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: envelope.Code, Domain: grpcErrorDomain}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
+
+// httpStatusFromGRPC is the inverse of grpcCode.
+func httpStatusFromGRPC(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// decodeGRPCError splits an error returned by a gRPC call like decodeError
+// splits error responses. Statuses without ErrorInfo, such as those of
+// failed connections, are endpoint errors as they are.
+func decodeGRPCError(err error) (serviceErr error, endpointErr error) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil, err
+	}
+	var envelope errorEnvelope
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Domain == grpcErrorDomain {
+			envelope = errorEnvelope{info.Reason, st.Message()}
+		}
+	}
+	if envelope.Code == "" {
+		return nil, err
+	}
+
+	httpStatus := httpStatusFromGRPC(st.Code())
+	e := errorFromEnvelope(envelope, httpStatus)
+	if isClientError(envelope.Code, httpStatus) {
+		return e, nil
+	}
+	return nil, e
+}
+
+// grpcErrors puts the service errors of a gRPC client endpoint into T, which
+// must embed failure, like decodeResponse does for HTTP.
+func grpcErrors[T any](next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := next(ctx, request)
+		if err == nil {
+			return response, nil
+		}
+		serviceErr, err := decodeGRPCError(err)
+		if err != nil {
+			return nil, err
+		}
+		var resp T
+		any(&resp).(interface{ fail(error) }).fail(serviceErr)
+		return resp, nil
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/sd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/shiiyan/learn-go/my-stringsvc3/pb"
+)
+
+func newGRPCUpstream(t *testing.T) string {
+	t.Helper()
+	return serveGRPC(t, stringService{})
+}
+
+func serveGRPC(t *testing.T, svc StringService) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected to listen, got %v", err)
+	}
+	srv := grpc.NewServer()
+	pb.RegisterStringServiceServer(srv, newGRPCServer(svc, noopTracer))
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	return "grpc://" + ln.Addr().String()
+}
+
+func TestGRPC_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	routes := map[string]route{"uppercase": routeRemote, "count": routeRemote}
+	svc, _ := newTestProxy(t, sd.FixedInstancer{newGRPCUpstream(t)}, routes, testProxyConfig(), discardBreakerMetrics())
+
+	t.Run("uppercases", func(t *testing.T) {
+		if v, err := svc.Uppercase(ctx, "istanbul", "tr"); v != "İSTANBUL" || err != nil {
+			t.Errorf("Expected İSTANBUL, got %q, %v", v, err)
+		}
+	})
+
+	t.Run("counts", func(t *testing.T) {
+		if n, err := svc.Count(ctx, "héllo wörld", CountRunes); n != 11 || err != nil {
+			t.Errorf("Expected 11 runes, got %d, %v", n, err)
+		}
+	})
+
+	t.Run("returns service errors", func(t *testing.T) {
+		if _, err := svc.Uppercase(ctx, "", ""); !errors.Is(err, ErrEmpty) {
+			t.Errorf("Expected ErrEmpty, got %v", err)
+		}
+		if _, err := svc.Count(ctx, "hello", "lines"); !errors.Is(err, ErrCountMode) {
+			t.Errorf("Expected ErrCountMode, got %v", err)
+		}
+	})
+
+	t.Run("answers service errors as statuses", func(t *testing.T) {
+		conn, err := dialGRPC(strings.TrimPrefix(newGRPCUpstream(t), "grpc://"))
+		if err != nil {
+			t.Fatalf("Expected to dial, got %v", err)
+		}
+		defer conn.Close()
+
+		_, err = pb.NewStringServiceClient(conn).Uppercase(ctx, &pb.UppercaseRequest{S: ""})
+		if status.Code(err) != codes.InvalidArgument || status.Convert(err).Message() != ErrEmpty.Error() {
+			t.Errorf("Expected InvalidArgument %q, got %v", ErrEmpty, err)
+		}
+	})
+
+	t.Run("returns upstream failures as endpoint errors", func(t *testing.T) {
+		broken, _ := newTestProxy(t, sd.FixedInstancer{}, routes, testProxyConfig(), discardBreakerMetrics())
+		cfg := testProxyConfig()
+		cfg.MaxAttempts = 1
+		front, _ := newTestProxy(t, sd.FixedInstancer{serveGRPC(t, broken)}, routes, cfg, discardBreakerMetrics())
+
+		_, err := front.Uppercase(ctx, "hello", "")
+		var up upstreamError
+		if envelope, _ := lookupError(err); !errors.As(err, &up) || envelope.Code != "no_upstreams" {
+			t.Errorf("Expected no_upstreams as upstream error, got %v", err)
+		}
+	})
+}
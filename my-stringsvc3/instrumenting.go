@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -14,25 +15,25 @@ type instrumentingMiddleware struct {
 	next           StringService
 }
 
-func (mw instrumentingMiddleware) Uppercase(s string) (output string, err error) {
+func (mw instrumentingMiddleware) Uppercase(ctx context.Context, s string, locale string) (output string, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "uppercase", "error", fmt.Sprint(err != nil)}
 		mw.requestCount.With(lvs...).Add(1)
 		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	output, err = mw.next.Uppercase(s)
+	output, err = mw.next.Uppercase(ctx, s, locale)
 	return
 }
 
-func (mw instrumentingMiddleware) Count(s string) (n int) {
+func (mw instrumentingMiddleware) Count(ctx context.Context, s string, mode CountMode) (n int, err error) {
 	defer func(begin time.Time) {
-		lvs := []string{"method", "count", "error", "false"}
+		lvs := []string{"method", "count", "error", fmt.Sprint(err != nil)}
 		mw.requestCount.With(lvs...).Add(1)
 		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 		mw.countResult.Observe(float64(n))
 	}(time.Now())
 
-	n = mw.next.Count(s)
+	n, err = mw.next.Count(ctx, s, mode)
 	return
 }
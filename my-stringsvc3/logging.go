@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"time"
 
 	"github.com/go-kit/log"
@@ -11,31 +12,34 @@ type loggingMiddleware struct {
 	next   StringService
 }
 
-func (mw loggingMiddleware) Uppercase(s string) (output string, err error) {
+func (mw loggingMiddleware) Uppercase(ctx context.Context, s string, locale string) (output string, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "uppercase",
 			"input", s,
+			"locale", locale,
 			"output", output,
 			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
 
-	output, err = mw.next.Uppercase(s)
+	output, err = mw.next.Uppercase(ctx, s, locale)
 	return
 }
 
-func (mw loggingMiddleware) Count(s string) (n int) {
+func (mw loggingMiddleware) Count(ctx context.Context, s string, mode CountMode) (n int, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "count",
 			"input", s,
+			"mode", mode,
 			"n", n,
+			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
 
-	n = mw.next.Count(s)
+	n, err = mw.next.Count(ctx, s, mode)
 	return
 }
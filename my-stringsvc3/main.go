@@ -2,24 +2,59 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"net/http"
+	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
-	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/go-kit/kit/sd"
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/shiiyan/learn-go/my-stringsvc3/pb"
 )
 
 func main() {
 	var (
-		listen = flag.String("listen", ":8080", "HTTP listen address")
-		proxy  = flag.String("proxy", "", "Optional comma-separated list of URLs to proxy uppercase requests")
+		listen         = flag.String("listen", ":8080", "HTTP listen address")
+		grpcListen     = flag.String("grpc-listen", "", "Optional gRPC listen address, e.g. :8082")
+		proxy          = flag.String("proxy", "", "Optional comma-separated list of URLs to proxy requests to; grpc://host:port calls over gRPC")
+		proxyFile      = flag.String("proxy-file", "", "Optional file listing proxy instances, one per line, watched for changes")
+		proxyDNS       = flag.String("proxy-dns", "", "Optional DNS SRV name resolving to proxy instances")
+		dnsResolver    = flag.String("dns-resolver", "", "DNS server for -proxy-dns, e.g. 127.0.0.1:53; system resolver if empty")
+		proxyRegistry  = flag.String("proxy-registry", "", "Optional registry URL listing proxy instances")
+		refresh        = flag.Duration("discovery-refresh", 5*time.Second, "How often -proxy-file, -proxy-dns and -proxy-registry are re-read")
+		healthInterval = flag.Duration("health-interval", 5*time.Second, "How often proxy instances are probed; 0 disables health checking")
+		serveRegistry  = flag.Bool("serve-registry", false, "Serve a self-registration registry on /registry")
+		registryTTL    = flag.Duration("registry-ttl", 30*time.Second, "How long a registration lasts without renewal")
+		register       = flag.String("register", "", "Optional registry URL to register this instance with")
+		advertise      = flag.String("advertise", "", "Address to register as, e.g. host:8080")
+		proxyConfigs   = flag.String("proxy-config", "", "Optional YAML file of proxy settings; flags given explicitly override it")
+		proxyRoutes    = flag.String("proxy-routes", "", "Per-method routing as method=local|remote|fallback, comma-separated (default uppercase=remote,count=local)")
+		traceExporter  = flag.String("trace", "", "Trace exporter: stdout writes spans to stdout; empty records none but still propagates traces")
 	)
+	cfg := defaultProxyConfig()
+	cfg.registerFlags(flag.CommandLine)
+	srvCfg := defaultServerConfig()
+	srvCfg.registerFlags(flag.CommandLine)
 	flag.Parse()
+	if *proxyConfigs != "" {
+		if err := cfg.load(*proxyConfigs); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		// parse again so explicit flags win over the file
+		flag.Parse()
+	}
 
 	var logger log.Logger
 	logger = log.NewLogfmtLogger(os.Stderr)
@@ -44,28 +79,157 @@ func main() {
 		Name:      "count_result",
 		Help:      "The result of each count method.",
 	}, []string{}) // no fields here
+	breakerState := stdprometheus.NewGaugeVec(stdprometheus.GaugeOpts{
+		Namespace: "my_group",
+		Subsystem: "string_service",
+		Name:      "proxy_breaker_state",
+		Help:      "Circuit breaker state per upstream: 0 closed, 1 half-open, 2 open.",
+	}, []string{"instance", "method"})
+	stdprometheus.MustRegister(breakerState)
+	breakers := breakerMetrics{
+		state: kitprometheus.NewGauge(breakerState),
+		transitions: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "my_group",
+			Subsystem: "string_service",
+			Name:      "proxy_breaker_transitions",
+			Help:      "Number of circuit breaker state changes per upstream.",
+		}, []string{"instance", "method", "from", "to"}),
+		forget: func(instance, method string) {
+			breakerState.Delete(stdprometheus.Labels{"instance": instance, "method": method})
+		},
+	}
+
+	tp, shutdownTracing, err := newTracerProvider(*traceExporter, *listen)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+	tracer := tp.Tracer(tracerName)
+
+	routes, err := parseRoutes(*proxyRoutes)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+
+	instancer, err := newInstancer(*proxy, *proxyFile, *proxyDNS, *dnsResolver, *proxyRegistry, *refresh, logger)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	if instancer != nil && *healthInterval > 0 {
+		instancer = newHealthChecker(instancer, tcpProbe, *healthInterval, *healthInterval/2, logger)
+	}
+
+	upstreams := newUpstreamBreakers()
 
 	var svc StringService
 	svc = stringService{}
-	svc = proxyingMiddleware(context.Background(), *proxy, logger)(svc)
+	svc = tracing(tracer, "service")(svc)
+	svc = proxyingMiddleware(instancer, routes, cfg, breakers, upstreams, tracer, logger)(svc)
+	svc = tracing(tracer, "proxying")(svc)
 	svc = loggingMiddleware{logger, svc}
+	svc = tracing(tracer, "logging")(svc)
 	svc = instrumentingMiddleware{requestCount, requestLatency, countResult, svc}
+	svc = tracing(tracer, "instrumenting")(svc)
 
-	uppercaseHandler := httptransport.NewServer(
-		makeUppercaseEndpoint(svc),
-		decodeUppercaseRequest,
-		encodeResponse,
-	)
-	countHandler := httptransport.NewServer(
-		makeCountEndpoint(svc),
-		decodeCountRequest,
-		encodeResponse,
-	)
+	mux := makeHTTPHandler(svc, srvCfg, tracer)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthz)
+	var draining atomic.Bool
+	mux.Handle("/readyz", readyz(&draining, upstreams.ready))
+	if *serveRegistry {
+		mux.Handle("/registry", newRegistry(*registryTTL))
+	}
+
+	srv := srvCfg.server(*listen, mux)
+	errc := make(chan error, 2)
+
+	if *register != "" {
+		if *advertise == "" {
+			logger.Log("err", "-register requires -advertise")
+			os.Exit(1)
+		}
+		registrar := newRegistryRegistrar(*register, *advertise, *registryTTL/3, logger)
+		registrar.Register()
+		// leave the registry as soon as draining starts
+		srv.RegisterOnShutdown(registrar.Deregister)
+		defer registrar.Deregister()
+	}
+
+	var grpcServer *grpc.Server
+	if *grpcListen != "" {
+		ln, err := net.Listen("tcp", *grpcListen)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		grpcServer = grpc.NewServer()
+		pb.RegisterStringServiceServer(grpcServer, newGRPCServer(svc, tracer))
+		go func() {
+			logger.Log("grpc_listen_on", *grpcListen)
+			errc <- fmt.Errorf("gRPC: %w", grpcServer.Serve(ln))
+		}()
+	}
+
+	go func() {
+		logger.Log("listen_on", *listen)
+		errc <- srv.ListenAndServe()
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	failed := false
+	select {
+	case err := <-errc:
+		logger.Log("err", err)
+		failed = true
+	case <-ctx.Done():
+		// a second signal kills the process without waiting for the drain
+		stop()
+		logger.Log("shutdown", "draining", "timeout", srvCfg.ShutdownTimeout)
+	}
+
+	draining.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), srvCfg.ShutdownTimeout)
+	defer cancel()
+	if err := shutdown(ctx, srv, grpcServer); err != nil {
+		logger.Log("shutdown", "incomplete", "err", err)
+		failed = true
+	} else {
+		logger.Log("shutdown", "complete")
+	}
+	if failed {
+		// os.Exit skips the deferred flush
+		shutdownTracing(context.Background())
+		os.Exit(1)
+	}
+}
 
-	http.Handle("/uppercase", uppercaseHandler)
-	http.Handle("/count", countHandler)
-	http.Handle("/metrics", promhttp.Handler())
+// newInstancer returns the proxy instance source selected by flags, or nil
+// when proxying is off.
+func newInstancer(static, file, dns, resolver, registry string, refresh time.Duration, logger log.Logger) (sd.Instancer, error) {
+	var instancers []sd.Instancer
+	if static != "" {
+		instancers = append(instancers, sd.FixedInstancer(split(static)))
+	}
+	if file != "" {
+		instancers = append(instancers, newFileInstancer(file, refresh, logger))
+	}
+	if dns != "" {
+		instancers = append(instancers, newDNSInstancer(dns, resolver, refresh, logger))
+	}
+	if registry != "" {
+		instancers = append(instancers, newRegistryInstancer(registry, refresh, logger))
+	}
 
-	logger.Log("listen_on", *listen)
-	logger.Log(http.ListenAndServe(*listen, nil))
+	switch len(instancers) {
+	case 0:
+		return nil, nil
+	case 1:
+		return instancers[0], nil
+	default:
+		return nil, errors.New("use only one of -proxy, -proxy-file, -proxy-dns and -proxy-registry")
+	}
 }
@@ -0,0 +1,169 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: stringsvc.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	StringService_Uppercase_FullMethodName = "/pb.StringService/Uppercase"
+	StringService_Count_FullMethodName     = "/pb.StringService/Count"
+)
+
+// StringServiceClient is the client API for StringService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// The string service definition. Errors are returned as gRPC statuses whose
+// google.rpc.ErrorInfo reason is the code of the HTTP error envelope.
+type StringServiceClient interface {
+	// Uppercases a string.
+	Uppercase(ctx context.Context, in *UppercaseRequest, opts ...grpc.CallOption) (*UppercaseReply, error)
+	// Counts the characters of a string.
+	Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*CountReply, error)
+}
+
+type stringServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStringServiceClient(cc grpc.ClientConnInterface) StringServiceClient {
+	return &stringServiceClient{cc}
+}
+
+func (c *stringServiceClient) Uppercase(ctx context.Context, in *UppercaseRequest, opts ...grpc.CallOption) (*UppercaseReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UppercaseReply)
+	err := c.cc.Invoke(ctx, StringService_Uppercase_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stringServiceClient) Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*CountReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CountReply)
+	err := c.cc.Invoke(ctx, StringService_Count_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StringServiceServer is the server API for StringService service.
+// All implementations must embed UnimplementedStringServiceServer
+// for forward compatibility.
+//
+// The string service definition. Errors are returned as gRPC statuses whose
+// google.rpc.ErrorInfo reason is the code of the HTTP error envelope.
+type StringServiceServer interface {
+	// Uppercases a string.
+	Uppercase(context.Context, *UppercaseRequest) (*UppercaseReply, error)
+	// Counts the characters of a string.
+	Count(context.Context, *CountRequest) (*CountReply, error)
+	mustEmbedUnimplementedStringServiceServer()
+}
+
+// UnimplementedStringServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedStringServiceServer struct{}
+
+func (UnimplementedStringServiceServer) Uppercase(context.Context, *UppercaseRequest) (*UppercaseReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Uppercase not implemented")
+}
+func (UnimplementedStringServiceServer) Count(context.Context, *CountRequest) (*CountReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Count not implemented")
+}
+func (UnimplementedStringServiceServer) mustEmbedUnimplementedStringServiceServer() {}
+func (UnimplementedStringServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeStringServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StringServiceServer will
+// result in compilation errors.
+type UnsafeStringServiceServer interface {
+	mustEmbedUnimplementedStringServiceServer()
+}
+
+func RegisterStringServiceServer(s grpc.ServiceRegistrar, srv StringServiceServer) {
+	// If the following call pancis, it indicates UnimplementedStringServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&StringService_ServiceDesc, srv)
+}
+
+func _StringService_Uppercase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UppercaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StringServiceServer).Uppercase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StringService_Uppercase_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StringServiceServer).Uppercase(ctx, req.(*UppercaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StringService_Count_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StringServiceServer).Count(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StringService_Count_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StringServiceServer).Count(ctx, req.(*CountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// StringService_ServiceDesc is the grpc.ServiceDesc for StringService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var StringService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.StringService",
+	HandlerType: (*StringServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Uppercase",
+			Handler:    _StringService_Uppercase_Handler,
+		},
+		{
+			MethodName: "Count",
+			Handler:    _StringService_Count_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "stringsvc.proto",
+}
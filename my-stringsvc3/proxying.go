@@ -2,99 +2,389 @@ package main
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"io"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/circuitbreaker"
 	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/metrics"
 	"github.com/go-kit/kit/ratelimit"
 	"github.com/go-kit/kit/sd"
 	"github.com/go-kit/kit/sd/lb"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
 	"github.com/sony/gobreaker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/time/rate"
+
+	"github.com/shiiyan/learn-go/my-stringsvc3/pb"
 )
 
+// route says where a StringService method is served.
+type route string
+
+const (
+	routeLocal    route = "local"
+	routeRemote   route = "remote"
+	routeFallback route = "fallback" // remote, served locally when upstreams fail
+)
+
+// proxyMethod describes how a StringService method travels to an upstream,
+// over HTTP or gRPC. Adding a method to proxyMethods is all the proxy
+// plumbing it needs.
+type proxyMethod struct {
+	path   string
+	decode httptransport.DecodeResponseFunc
+
+	grpcMethod string
+	grpcEncode grpctransport.EncodeRequestFunc
+	grpcDecode grpctransport.DecodeResponseFunc
+	grpcReply  interface{}
+	grpcErrors endpoint.Middleware
+}
+
+var proxyMethods = map[string]proxyMethod{
+	"uppercase": {
+		"/uppercase", decodeResponse[uppercaseResponse],
+		"Uppercase", encodeGRPCUppercaseRequest, decodeGRPCUppercaseResponse, pb.UppercaseReply{}, grpcErrors[uppercaseResponse],
+	},
+	"count": {
+		"/count", decodeResponse[countResponse],
+		"Count", encodeGRPCCountRequest, decodeGRPCCountResponse, pb.CountReply{}, grpcErrors[countResponse],
+	},
+}
+
+// defaultRoutes keeps Count local, as it was before it could be proxied.
+var defaultRoutes = map[string]route{
+	"uppercase": routeRemote,
+	"count":     routeLocal,
+}
+
+// parseRoutes reads "uppercase=remote,count=fallback" over defaultRoutes.
+func parseRoutes(s string) (map[string]route, error) {
+	routes := map[string]route{}
+	for method, r := range defaultRoutes {
+		routes[method] = r
+	}
+	if s == "" {
+		return routes, nil
+	}
+
+	for _, rule := range split(s) {
+		method, r, _ := strings.Cut(rule, "=")
+		if _, ok := proxyMethods[method]; !ok {
+			return nil, fmt.Errorf("unknown method %q in route %q", method, rule)
+		}
+		switch route(r) {
+		case routeLocal, routeRemote, routeFallback:
+			routes[method] = route(r)
+		default:
+			return nil, fmt.Errorf("unknown route %q for %s, want local, remote or fallback", r, method)
+		}
+	}
+	return routes, nil
+}
+
 type proxymw struct {
-	ctx       context.Context
 	next      StringService
-	uppercase endpoint.Endpoint
+	routes    map[string]route
+	endpoints map[string]endpoint.Endpoint
+	logger    log.Logger
 }
 
-func (mw proxymw) Uppercase(s string) (string, error) {
-	response, err := mw.uppercase(mw.ctx, uppercaseRequest{S: s})
+// proxy sends request to the upstreams if method is routed there. handled is
+// false when the caller should serve the method locally instead.
+func (mw proxymw) proxy(ctx context.Context, method string, request interface{}) (response interface{}, handled bool, err error) {
+	r := mw.routes[method]
+	e, ok := mw.endpoints[method]
+	if r == routeLocal || !ok {
+		return nil, false, nil
+	}
+
+	response, err = e(ctx, request)
+	if err != nil && r == routeFallback {
+		mw.logger.Log("method", method, "fallback", "local", "err", err)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, upstreamError{err}
+	}
+	return response, true, nil
+}
+
+func (mw proxymw) Uppercase(ctx context.Context, s string, locale string) (string, error) {
+	response, handled, err := mw.proxy(ctx, "uppercase", uppercaseRequest{S: s, Locale: locale})
+	if !handled {
+		return mw.next.Uppercase(ctx, s, locale)
+	}
 	if err != nil {
 		return "", err
 	}
 
 	resp := response.(uppercaseResponse)
-	if resp.Err != "" {
-		return resp.V, errors.New(resp.Err)
+	return resp.V, resp.Err
+}
+
+func (mw proxymw) Count(ctx context.Context, s string, mode CountMode) (int, error) {
+	response, handled, err := mw.proxy(ctx, "count", countRequest{S: s, Mode: string(mode)})
+	if !handled {
+		return mw.next.Count(ctx, s, mode)
+	}
+	if err != nil {
+		return 0, err
 	}
 
-	return resp.V, nil
+	resp := response.(countResponse)
+	return resp.V, resp.Err
+}
+
+// breakerMetrics records the state of each upstream's circuit breaker.
+type breakerMetrics struct {
+	state       metrics.Gauge   // 0 closed, 1 half-open, 2 open; labeled by instance and method
+	transitions metrics.Counter // labeled by instance, method, from and to
+
+	// forget, if set, drops the state of an instance that went away, so
+	// the gauge doesn't keep reporting it.
+	forget func(instance, method string)
+}
+
+// upstreamBreakers tracks the breakers of the live upstream instances of
+// each remote method, so readiness can tell whether requests would get
+// through. Fallback methods are not tracked as they are served either way.
+type upstreamBreakers struct {
+	mu       sync.Mutex
+	breakers map[string]map[string]*gobreaker.CircuitBreaker // by method, then instance
 }
 
-func (mw proxymw) Count(s string) int {
-	return mw.next.Count(s)
+func newUpstreamBreakers() *upstreamBreakers {
+	return &upstreamBreakers{breakers: map[string]map[string]*gobreaker.CircuitBreaker{}}
 }
 
-func proxyingMiddleware(ctx context.Context, proxyUrls string, logger log.Logger) ServiceMiddleware {
-	if proxyUrls == "" {
+func (u *upstreamBreakers) watch(method string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.breakers[method] = map[string]*gobreaker.CircuitBreaker{}
+}
+
+func (u *upstreamBreakers) add(method, instance string, cb *gobreaker.CircuitBreaker) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if m, ok := u.breakers[method]; ok {
+		m[instance] = cb
+	}
+}
+
+func (u *upstreamBreakers) remove(method, instance string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.breakers[method], instance)
+}
+
+// ready fails if some remote method has no upstream whose breaker would let
+// a request through.
+func (u *upstreamBreakers) ready() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for method, instances := range u.breakers {
+		open := 0
+		for _, cb := range instances {
+			if cb.State() == gobreaker.StateOpen {
+				open++
+			}
+		}
+		if open == len(instances) {
+			return fmt.Errorf("%s: no upstream available, %d of %d breakers open", method, open, len(instances))
+		}
+	}
+	return nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func proxyingMiddleware(instancer sd.Instancer, routes map[string]route, cfg proxyConfig, bm breakerMetrics, ub *upstreamBreakers, tracer trace.Tracer, logger log.Logger) ServiceMiddleware {
+	if instancer == nil {
 		logger.Log("proxy_to", "none")
 		return func(next StringService) StringService { return next }
 	}
 
+	// Methods share each upstream's rate limit but trip separate breakers.
 	var (
-		proxyUrlList = split(proxyUrls)
-		endPointer   sd.FixedEndpointer
+		mu       sync.Mutex
+		limiters = map[string]*rate.Limiter{}
 	)
+	limiter := func(instance string) *rate.Limiter {
+		mu.Lock()
+		defer mu.Unlock()
+		if limiters[instance] == nil {
+			limiters[instance] = rate.NewLimiter(rate.Limit(cfg.QPS), cfg.Burst)
+		}
+		return limiters[instance]
+	}
 
-	var (
-		qps         = 10
-		maxAttempts = 3
-		maxTime     = 250 * time.Millisecond
-	)
+	endpoints := map[string]endpoint.Endpoint{}
+	for method, r := range routes {
+		if r == routeLocal {
+			continue
+		}
+		pm := proxyMethods[method]
+		if r == routeRemote {
+			ub.watch(method)
+		}
 
-	for _, proxyUrl := range proxyUrlList {
-		var e endpoint.Endpoint
-		e = makeUppercaseProxy(proxyUrl)
-		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(e)
-		e = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Second), qps))(e)
-		endPointer = append(endPointer, e)
-	}
+		// Each discovered instance gets its own breaker; the endpointer
+		// builds them as instances appear and drops them as they go.
+		factory := func(instance string) (endpoint.Endpoint, io.Closer, error) {
+			e, closer, err := makeProxyEndpoint(instance, pm)
+			if err != nil {
+				return nil, nil, err
+			}
+			cb := newBreaker(instance, method, cfg.Breaker, bm, logger)
+			ub.add(method, instance, cb)
+			e = traceEndpoint(tracer, "proxy."+method+".call",
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attribute.String("instance", instance)),
+			)(e)
+			e = attemptTimeout(cfg.AttemptTimeout)(e)
+			e = circuitbreaker.Gobreaker(cb)(e)
+			e = ratelimit.NewErroringLimiter(limiter(instance))(e)
+			e = traceAttempt(tracer, method, instance)(e)
+			return e, closerFunc(func() error {
+				ub.remove(method, instance)
+				if bm.forget != nil {
+					bm.forget(instance, method)
+				}
+				if closer != nil {
+					return closer.Close()
+				}
+				return nil
+			}), nil
+		}
 
-	balancer := lb.NewRoundRobin(endPointer)
-	retry := lb.Retry(maxAttempts, maxTime, balancer)
+		endpointer := sd.NewEndpointer(instancer, factory, log.With(logger, "method", method))
+		balancer := lb.NewRoundRobin(endpointer)
+		endpoints[method] = traceRetries(tracer, method)(retrying(cfg, balancer))
+	}
 
 	return func(next StringService) StringService {
-		return proxymw{ctx, next, retry}
+		return proxymw{next, routes, endpoints, logger}
 	}
 }
 
-func makeUppercaseProxy(proxyURL string) endpoint.Endpoint {
+func newBreaker(instance, method string, cfg breakerConfig, bm breakerMetrics, logger log.Logger) *gobreaker.CircuitBreaker {
+	bm.state.With("instance", instance, "method", method).Set(float64(gobreaker.StateClosed))
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        instance,
+		MaxRequests: cfg.HalfOpenRequests,
+		Interval:    cfg.Interval,
+		Timeout:     cfg.OpenTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= cfg.ConsecutiveFailures
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			logger.Log("breaker", name, "method", method, "from", from, "to", to)
+			bm.state.With("instance", name, "method", method).Set(float64(to))
+			bm.transitions.With("instance", name, "method", method, "from", from.String(), "to", to.String()).Add(1)
+		},
+	})
+}
+
+// retrying tries requests on balancer up to MaxAttempts times within
+// RetryBudget, backing off between attempts. The backoff gives up as soon as
+// the request is canceled or runs out of budget.
+func retrying(cfg proxyConfig, balancer lb.Balancer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, cfg.RetryBudget)
+		defer cancel()
+		retry := lb.RetryWithCallback(cfg.RetryBudget, balancer, func(n int, _ error) (bool, error) {
+			if n >= cfg.MaxAttempts {
+				return false, nil
+			}
+			select {
+			case <-time.After(backoff(cfg, n)):
+				return true, nil
+			case <-ctx.Done():
+				return false, ctx.Err()
+			}
+		})
+		return retry(ctx, request)
+	}
+}
+
+// attemptTimeout bounds a single attempt, leaving the retry budget for the
+// attempts that follow.
+func attemptTimeout(d time.Duration) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		if d <= 0 {
+			return next
+		}
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, request)
+		}
+	}
+}
+
+// backoff is the wait after the nth failed attempt: Backoff doubled for each
+// earlier failure, capped at MaxBackoff.
+func backoff(cfg proxyConfig, n int) time.Duration {
+	d := cfg.Backoff
+	for i := 1; i < n && d < cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if cfg.MaxBackoff > 0 && d > cfg.MaxBackoff {
+		d = cfg.MaxBackoff
+	}
+	return d
+}
+
+// makeProxyEndpoint calls pm on the upstream at proxyURL: over gRPC for
+// grpc://host:port, otherwise over HTTP. The method's path replaces any path
+// in an HTTP proxyURL, so the single-method form host:8080/uppercase of older
+// -proxy flags still reaches every method. The closer, if any, releases the
+// connection once the instance goes away.
+func makeProxyEndpoint(proxyURL string, pm proxyMethod) (endpoint.Endpoint, io.Closer, error) {
+	if target, ok := strings.CutPrefix(proxyURL, "grpc://"); ok {
+		conn, err := dialGRPC(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pm.grpcErrors(grpctransport.NewClient(
+			conn,
+			"pb.StringService",
+			pm.grpcMethod,
+			pm.grpcEncode,
+			pm.grpcDecode,
+			pm.grpcReply,
+			grpctransport.ClientBefore(traceToGRPC),
+		).Endpoint()), conn, nil
+	}
+
 	if !strings.HasPrefix(proxyURL, "http") {
 		proxyURL = "http://" + proxyURL
 	}
 
 	u, err := url.Parse(proxyURL)
 	if err != nil {
-		panic(err)
-	}
-
-	if u.Path == "" {
-		u.Path = "/uppercase"
+		return nil, nil, err
 	}
 
+	u.Path, u.RawPath = pm.path, ""
 	return httptransport.NewClient(
 		"GET",
 		u,
 		encodeRequest,
-		decodeUppercaseResponse,
-	).Endpoint()
+		pm.decode,
+		httptransport.ClientBefore(timeoutToHTTP, traceToHTTP),
+	).Endpoint(), nil, nil
 }
 
 func split(s string) []string {
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/log"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+var noopTracer trace.Tracer = noop.NewTracerProvider().Tracer(tracerName)
+
+// newUpstream serves stringService over HTTP, as a proxied instance would.
+func newUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(makeHTTPHandler(stringService{}, defaultServerConfig(), noopTracer))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newRecordingUpstream serves stringService like newUpstream and records the
+// paths requested.
+func newRecordingUpstream(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	h := makeHTTPHandler(stringService{}, defaultServerConfig(), noopTracer)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(paths)
+	}
+}
+
+// newFailingUpstream answers every request with 500 and counts them.
+func newFailingUpstream(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func discardBreakerMetrics() breakerMetrics {
+	return breakerMetrics{state: discard.NewGauge(), transitions: discard.NewCounter()}
+}
+
+// testProxyConfig retries without waiting and keeps breakers closed.
+func testProxyConfig() proxyConfig {
+	cfg := defaultProxyConfig()
+	cfg.QPS, cfg.Burst = 1000, 1000
+	cfg.RetryBudget = time.Second
+	cfg.AttemptTimeout = time.Second
+	cfg.Backoff, cfg.MaxBackoff = 0, 0
+	return cfg
+}
+
+func newTestProxy(t *testing.T, instancer sd.Instancer, routes map[string]route, cfg proxyConfig, bm breakerMetrics) (StringService, *upstreamBreakers) {
+	t.Helper()
+	ub := newUpstreamBreakers()
+	svc := proxyingMiddleware(instancer, routes, cfg, bm, ub, noopTracer, log.NewNopLogger())(stringService{})
+	return svc, ub
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := proxyConfig{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{1, 10 * time.Millisecond},
+		{2, 20 * time.Millisecond},
+		{3, 40 * time.Millisecond},
+		{4, 50 * time.Millisecond},
+		{10, 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := backoff(cfg, tt.n); got != tt.want {
+			t.Errorf("Expected backoff %v after attempt %d, got %v", tt.want, tt.n, got)
+		}
+	}
+}
+
+func TestProxyConfig_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.yaml")
+	os.WriteFile(path, []byte("max_attempts: 5\nbackoff: 25ms\nbreaker:\n  consecutive_failures: 2\n"), 0o644)
+
+	cfg := defaultProxyConfig()
+	if err := cfg.load(path); err != nil {
+		t.Fatalf("Expected config to load, got %v", err)
+	}
+
+	if cfg.MaxAttempts != 5 || cfg.Backoff != 25*time.Millisecond || cfg.Breaker.ConsecutiveFailures != 2 {
+		t.Errorf("Expected values from the file, got %+v", cfg)
+	}
+	if def := defaultProxyConfig(); cfg.QPS != def.QPS || cfg.Breaker.OpenTimeout != def.Breaker.OpenTimeout {
+		t.Errorf("Expected fields missing from the file to keep defaults, got %+v", cfg)
+	}
+}
+
+func TestProxying_Retries(t *testing.T) {
+	ctx := context.Background()
+	bad, hits := newFailingUpstream(t)
+	good := newUpstream(t)
+
+	svc, _ := newTestProxy(t, sd.FixedInstancer{bad.URL, good.URL}, defaultRoutes, testProxyConfig(), discardBreakerMetrics())
+
+	for range 4 {
+		v, err := svc.Uppercase(ctx, "hello", "")
+		if err != nil || v != "HELLO" {
+			t.Fatalf("Expected HELLO from the healthy upstream, got %q, %v", v, err)
+		}
+	}
+	if hits.Load() == 0 {
+		t.Errorf("Expected the failing upstream to be tried")
+	}
+}
+
+func TestProxying_BreakerOpens(t *testing.T) {
+	ctx := context.Background()
+	bad, hits := newFailingUpstream(t)
+	cfg := testProxyConfig()
+	cfg.MaxAttempts = 1
+	cfg.Breaker.ConsecutiveFailures = 2
+
+	svc, ub := newTestProxy(t, sd.FixedInstancer{bad.URL}, defaultRoutes, cfg, discardBreakerMetrics())
+
+	for range 2 {
+		if _, err := svc.Uppercase(ctx, "hello", ""); err == nil {
+			t.Fatalf("Expected the failing upstream to fail")
+		}
+	}
+	_, err := svc.Uppercase(ctx, "hello", "")
+
+	if envelope, _ := lookupError(err); envelope.Code != "breaker_open" {
+		t.Errorf("Expected open breaker, got %v", err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("Expected the open breaker to hold back the third request, got %d upstream hits", hits.Load())
+	}
+	if err := ub.ready(); err == nil {
+		t.Errorf("Expected not ready with every breaker open")
+	}
+}
+
+type balancerFunc func() (endpoint.Endpoint, error)
+
+func (f balancerFunc) Endpoint() (endpoint.Endpoint, error) { return f() }
+
+func TestRetrying_StopsBackingOff(t *testing.T) {
+	failing := balancerFunc(func() (endpoint.Endpoint, error) {
+		return func(context.Context, interface{}) (interface{}, error) {
+			return nil, errors.New("boom")
+		}, nil
+	})
+	cfg := proxyConfig{MaxAttempts: 3, RetryBudget: time.Minute, Backoff: time.Minute, MaxBackoff: time.Minute}
+
+	t.Run("when the request is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := retrying(cfg, failing)(ctx, nil)
+
+		if envelope, _ := lookupError(err); envelope.Code != "deadline_exceeded" {
+			t.Errorf("Expected deadline exceeded, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected backoff to stop with the request, took %v", elapsed)
+		}
+	})
+
+	t.Run("when the retry budget runs out", func(t *testing.T) {
+		cfg := cfg
+		cfg.RetryBudget = 50 * time.Millisecond
+
+		start := time.Now()
+		_, err := retrying(cfg, failing)(context.Background(), nil)
+
+		if err == nil {
+			t.Errorf("Expected an error")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected backoff to stop with the budget, took %v", elapsed)
+		}
+	})
+}
+
+func TestProxying_ForgetsRemovedInstances(t *testing.T) {
+	instancer := newInstanceCache()
+	instancer.update(sd.Event{Instances: []string{"a:8080"}})
+	forgotten := make(chan string, 4)
+	bm := discardBreakerMetrics()
+	bm.forget = func(instance, method string) { forgotten <- method + " " + instance }
+
+	newTestProxy(t, instancer, defaultRoutes, testProxyConfig(), bm)
+	// the endpointer builds a:8080 from the first event, then drops it
+	time.Sleep(20 * time.Millisecond)
+	instancer.update(sd.Event{Instances: []string{}})
+
+	select {
+	case got := <-forgotten:
+		if got != "uppercase a:8080" {
+			t.Errorf("Expected uppercase a:8080 forgotten, got %s", got)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the removed instance's breaker state to be forgotten")
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]route
+		wantErr bool
+	}{
+		{"defaults", "", defaultRoutes, false},
+		{"overrides one method", "count=fallback", map[string]route{"uppercase": routeRemote, "count": routeFallback}, false},
+		{"overrides both", "uppercase=local, count=remote", map[string]route{"uppercase": routeLocal, "count": routeRemote}, false},
+		{"rejects unknown method", "reverse=remote", nil, true},
+		{"rejects unknown route", "count=nearby", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRoutes(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected routes %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProxying_Routes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("serves each method where it is routed", func(t *testing.T) {
+		upstream, paths := newRecordingUpstream(t)
+		routes := map[string]route{"uppercase": routeLocal, "count": routeRemote}
+		svc, _ := newTestProxy(t, sd.FixedInstancer{upstream.URL}, routes, testProxyConfig(), discardBreakerMetrics())
+
+		svc.Uppercase(ctx, "hello", "")
+		n, err := svc.Count(ctx, "hello", CountBytes)
+
+		if n != 5 || err != nil {
+			t.Errorf("Expected remote count 5, got %d, %v", n, err)
+		}
+		if got := paths(); !reflect.DeepEqual(got, []string{"/count"}) {
+			t.Errorf("Expected only /count proxied, got %v", got)
+		}
+	})
+
+	t.Run("replaces the path of a single-method proxy URL", func(t *testing.T) {
+		upstream, paths := newRecordingUpstream(t)
+		routes := map[string]route{"uppercase": routeRemote, "count": routeRemote}
+		svc, _ := newTestProxy(t, sd.FixedInstancer{upstream.URL + "/uppercase"}, routes, testProxyConfig(), discardBreakerMetrics())
+
+		svc.Uppercase(ctx, "hello", "")
+		svc.Count(ctx, "hello", CountBytes)
+
+		if got := paths(); !reflect.DeepEqual(got, []string{"/uppercase", "/count"}) {
+			t.Errorf("Expected /uppercase and /count, got %v", got)
+		}
+	})
+
+	t.Run("falls back to local when upstreams fail", func(t *testing.T) {
+		bad, hits := newFailingUpstream(t)
+		routes := map[string]route{"uppercase": routeFallback, "count": routeFallback}
+		svc, ub := newTestProxy(t, sd.FixedInstancer{bad.URL}, routes, testProxyConfig(), discardBreakerMetrics())
+
+		v, err := svc.Uppercase(ctx, "hello", "")
+		n, countErr := svc.Count(ctx, "hello", CountBytes)
+
+		if v != "HELLO" || err != nil || n != 5 || countErr != nil {
+			t.Errorf("Expected local answers, got %q, %v and %d, %v", v, err, n, countErr)
+		}
+		if hits.Load() == 0 {
+			t.Errorf("Expected the upstream to be tried first")
+		}
+		if err := ub.ready(); err != nil {
+			t.Errorf("Expected fallback methods not to affect readiness, got %v", err)
+		}
+	})
+
+	t.Run("fails remote count when upstreams fail", func(t *testing.T) {
+		bad, _ := newFailingUpstream(t)
+		routes := map[string]route{"uppercase": routeLocal, "count": routeRemote}
+		svc, _ := newTestProxy(t, sd.FixedInstancer{bad.URL}, routes, testProxyConfig(), discardBreakerMetrics())
+
+		_, err := svc.Count(ctx, "hello", CountBytes)
+
+		if envelope, status := lookupError(err); envelope.Code != "upstream_error" || status != http.StatusBadGateway {
+			t.Errorf("Expected upstream_error with 502, got %+v, %d", envelope, status)
+		}
+	})
+}
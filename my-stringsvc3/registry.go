@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/log"
+)
+
+// registry is a minimal self-registration service discovery server.
+// Instances register their address and must re-register within ttl to stay
+// listed.
+type registry struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	instances map[string]time.Time // address -> expiry
+}
+
+func newRegistry(ttl time.Duration) *registry {
+	return &registry{ttl: ttl, now: time.Now, instances: map[string]time.Time{}}
+}
+
+type registration struct {
+	Addr string `json:"addr"`
+}
+
+func (reg *registry) register(addr string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.instances[addr] = reg.now().Add(reg.ttl)
+}
+
+func (reg *registry) deregister(addr string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.instances, addr)
+}
+
+func (reg *registry) list() []string {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	now := reg.now()
+	instances := []string{}
+	for addr, expiry := range reg.instances {
+		if now.After(expiry) {
+			delete(reg.instances, addr)
+			continue
+		}
+		instances = append(instances, addr)
+	}
+	sort.Strings(instances)
+	return instances
+}
+
+// ServeHTTP serves
+//
+//	GET    /registry   registered instances
+//	POST   /registry   register or renew {"addr": "host:port"}
+//	DELETE /registry   deregister {"addr": "host:port"}
+func (reg *registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(reg.list())
+		return
+	}
+
+	var req registration
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Addr == "" {
+		http.Error(w, "expected {\"addr\": \"host:port\"}", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		reg.register(req.Addr)
+	case http.MethodDelete:
+		reg.deregister(req.Addr)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// registryURL accepts a bare host:port like the -proxy flag does.
+func registryURL(s string) string {
+	if !strings.HasPrefix(s, "http") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err == nil && (u.Path == "" || u.Path == "/") {
+		u.Path = "/registry"
+	}
+	return u.String()
+}
+
+// newRegistryInstancer polls a registry's instance list every interval.
+func newRegistryInstancer(registry string, interval time.Duration, logger log.Logger) sd.Instancer {
+	target := registryURL(registry)
+	client := &http.Client{Timeout: interval}
+	return newPollingInstancer(target, interval, func() ([]string, error) {
+		resp, err := client.Get(target)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("registry answered %s", resp.Status)
+		}
+		var instances []string
+		err = json.NewDecoder(resp.Body).Decode(&instances)
+		return instances, err
+	}, logger)
+}
+
+// registryRegistrar implements sd.Registrar against a registry, renewing the
+// registration every interval until Deregister is called.
+type registryRegistrar struct {
+	target   string
+	addr     string
+	interval time.Duration
+	client   *http.Client
+	logger   log.Logger
+
+	mu   sync.Mutex
+	quit chan struct{}
+}
+
+func newRegistryRegistrar(registry, addr string, interval time.Duration, logger log.Logger) *registryRegistrar {
+	return &registryRegistrar{
+		target:   registryURL(registry),
+		addr:     addr,
+		interval: interval,
+		client:   &http.Client{Timeout: interval},
+		logger:   log.With(logger, "registry", registry, "advertise", addr),
+	}
+}
+
+func (r *registryRegistrar) Register() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.quit != nil {
+		return
+	}
+	r.quit = make(chan struct{})
+	r.send(http.MethodPost)
+
+	go func(quit chan struct{}) {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.send(http.MethodPost)
+			case <-quit:
+				return
+			}
+		}
+	}(r.quit)
+}
+
+func (r *registryRegistrar) Deregister() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.quit == nil {
+		return
+	}
+	close(r.quit)
+	r.quit = nil
+	r.send(http.MethodDelete)
+}
+
+func (r *registryRegistrar) send(method string) {
+	body, _ := json.Marshal(registration{Addr: r.addr})
+	req, err := http.NewRequest(method, r.target, bytes.NewReader(body))
+	if err != nil {
+		r.logger.Log("err", err)
+		return
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.logger.Log("method", method, "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		r.logger.Log("method", method, "err", resp.Status)
+	}
+}
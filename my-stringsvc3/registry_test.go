@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/log"
+)
+
+func TestRegistry_TTL(t *testing.T) {
+	now := time.Date(2025, time.June, 30, 12, 0, 0, 0, time.UTC)
+	reg := newRegistry(10 * time.Second)
+	reg.now = func() time.Time { return now }
+
+	reg.register("a:8080")
+	now = now.Add(5 * time.Second)
+	reg.register("b:8080")
+
+	if got := reg.list(); !reflect.DeepEqual(got, []string{"a:8080", "b:8080"}) {
+		t.Errorf("Expected both instances, got %v", got)
+	}
+
+	now = now.Add(6 * time.Second)
+	if got := reg.list(); !reflect.DeepEqual(got, []string{"b:8080"}) {
+		t.Errorf("Expected a:8080 to expire, got %v", got)
+	}
+
+	// renewing extends the registration
+	reg.register("b:8080")
+	now = now.Add(9 * time.Second)
+	if got := reg.list(); !reflect.DeepEqual(got, []string{"b:8080"}) {
+		t.Errorf("Expected renewed b:8080 to stay, got %v", got)
+	}
+}
+
+func TestRegistry_HTTP(t *testing.T) {
+	reg := newRegistry(time.Minute)
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+
+	send := func(method string, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL, bytes.NewBufferString(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected %s to succeed, got %v", method, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := send("POST", `{"addr": ""}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without addr, got %d", code)
+	}
+
+	registrar := newRegistryRegistrar(srv.URL, "a:8080", time.Minute, log.NewNopLogger())
+	registrar.Register()
+
+	instancer := newRegistryInstancer(srv.URL, 10*time.Millisecond, log.NewNopLogger())
+	defer instancer.(*pollingInstancer).Stop()
+	events := make(chan sd.Event, 16)
+	instancer.Register(events)
+	defer instancer.Deregister(events)
+	awaitInstances(t, events, []string{"a:8080"}, time.Second)
+
+	registrar.Deregister()
+	awaitInstances(t, events, []string{}, time.Second)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected list to succeed, got %v", err)
+	}
+	defer resp.Body.Close()
+	var listed []string
+	json.NewDecoder(resp.Body).Decode(&listed)
+	if len(listed) != 0 {
+		t.Errorf("Expected no instances after deregister, got %v", listed)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// serverConfig bounds how long connections may take and how long a shutdown
+// waits for requests in flight.
+type serverConfig struct {
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+}
+
+func defaultServerConfig() serverConfig {
+	return serverConfig{
+		ReadTimeout:     5 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		IdleTimeout:     60 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+	}
+}
+
+func (c *serverConfig) registerFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "Time allowed to read a request, body included; 0 is unlimited")
+	fs.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "Time allowed to write a response; 0 is unlimited")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "How long a keep-alive connection may wait for its next request")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "How long a shutdown waits for requests in flight")
+}
+
+func (c serverConfig) server(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      h,
+		ReadTimeout:  c.ReadTimeout,
+		WriteTimeout: c.WriteTimeout,
+		IdleTimeout:  c.IdleTimeout,
+	}
+}
+
+// shutdown stops both servers taking new requests and waits for those in
+// flight until ctx is done, when the remaining connections are cut and an
+// error is returned. grpcServer may be nil.
+func shutdown(ctx context.Context, srv *http.Server, grpcServer *grpc.Server) (err error) {
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		defer func() {
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				grpcServer.Stop()
+				if err == nil {
+					err = fmt.Errorf("gRPC: %w", ctx.Err())
+				}
+			}
+		}()
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+		return err
+	}
+	return nil
+}
+
+// healthz reports the process is alive.
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// errDraining fails readiness checks once a shutdown has begun.
+var errDraining = errors.New("draining")
+
+// readyz reports whether requests can be served, as judged by ready. It fails
+// once draining is set, so load balancers stop sending requests while those
+// in flight finish.
+func readyz(draining *atomic.Bool, ready func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		err := ready()
+		if draining.Load() {
+			err = errDraining
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+}
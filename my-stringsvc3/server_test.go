@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+
+	"github.com/shiiyan/learn-go/my-stringsvc3/pb"
+)
+
+func TestReadyz(t *testing.T) {
+	ub := newUpstreamBreakers()
+	var draining atomic.Bool
+	srv := httptest.NewServer(readyz(&draining, ub.ready))
+	defer srv.Close()
+
+	status := func() int {
+		t.Helper()
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Expected readyz to answer, got %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := status(); got != http.StatusOK {
+		t.Errorf("Expected ready without remote methods, got %d", got)
+	}
+
+	ub.watch("uppercase")
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready without upstreams, got %d", got)
+	}
+
+	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		ReadyToTrip: func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		Timeout:     time.Minute,
+	})
+	ub.add("uppercase", "a:8080", cb)
+	if got := status(); got != http.StatusOK {
+		t.Errorf("Expected ready with a closed breaker, got %d", got)
+	}
+
+	cb.Execute(func() (interface{}, error) { return nil, io.ErrUnexpectedEOF })
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready with every breaker open, got %d", got)
+	}
+
+	ub.remove("uppercase", "a:8080")
+	ub.add("uppercase", "b:8080", gobreaker.NewCircuitBreaker(gobreaker.Settings{}))
+	if got := status(); got != http.StatusOK {
+		t.Errorf("Expected ready once a closed breaker replaces the open one, got %d", got)
+	}
+
+	draining.Store(true)
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready while draining, got %d", got)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	// serve answers after delay, once a request is in flight
+	serve := func(t *testing.T, delay time.Duration) (*httptest.Server, <-chan error) {
+		t.Helper()
+		started := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			close(started)
+			time.Sleep(delay)
+			w.Write([]byte("done\n"))
+		}))
+		t.Cleanup(srv.Close)
+
+		result := make(chan error, 1)
+		go func() {
+			resp, err := http.Get(srv.URL)
+			if err == nil {
+				_, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			result <- err
+		}()
+		<-started
+		return srv, result
+	}
+
+	t.Run("drains requests in flight", func(t *testing.T) {
+		srv, result := serve(t, 50*time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		if err := shutdown(ctx, srv.Config, nil); err != nil {
+			t.Errorf("Expected a complete shutdown, got %v", err)
+		}
+		if err := <-result; err != nil {
+			t.Errorf("Expected the request in flight to finish, got %v", err)
+		}
+	})
+
+	t.Run("cuts requests outlasting the timeout", func(t *testing.T) {
+		srv, result := serve(t, 200*time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		if err := shutdown(ctx, srv.Config, nil); err == nil {
+			t.Errorf("Expected an incomplete shutdown")
+		}
+		if err := <-result; err == nil {
+			t.Errorf("Expected the cut request to fail")
+		}
+	})
+
+	t.Run("reports cutting gRPC calls outlasting the timeout", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		defer srv.Close()
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Expected to listen, got %v", err)
+		}
+		started := make(chan struct{})
+		grpcServer := grpc.NewServer()
+		pb.RegisterStringServiceServer(grpcServer, newGRPCServer(startedService{slowService{delay: 200 * time.Millisecond}, started}, noopTracer))
+		go grpcServer.Serve(ln)
+
+		conn, err := dialGRPC(ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Expected to dial, got %v", err)
+		}
+		defer conn.Close()
+		result := make(chan error, 1)
+		go func() {
+			_, err := pb.NewStringServiceClient(conn).Uppercase(context.Background(), &pb.UppercaseRequest{S: "hello"})
+			result <- err
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := shutdown(ctx, srv.Config, grpcServer); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected an incomplete shutdown, got %v", err)
+		}
+		if err := <-result; err == nil {
+			t.Errorf("Expected the cut call to fail")
+		}
+	})
+}
+
+// startedService closes started when Uppercase is called.
+type startedService struct {
+	slowService
+	started chan struct{}
+}
+
+func (s startedService) Uppercase(ctx context.Context, str, locale string) (string, error) {
+	close(s.started)
+	return s.slowService.Uppercase(ctx, str, locale)
+}
+
+type slowService struct {
+	stringService
+	delay time.Duration
+}
+
+func (s slowService) Uppercase(ctx context.Context, str, locale string) (string, error) {
+	time.Sleep(s.delay)
+	return s.stringService.Uppercase(ctx, str, locale)
+}
+
+func TestBatch_BeforeWriteTimeout(t *testing.T) {
+	cfg := defaultServerConfig()
+	cfg.WriteTimeout = 200 * time.Millisecond
+	srv := httptest.NewUnstartedServer(makeHTTPHandler(slowService{delay: 20 * time.Millisecond}, cfg, noopTracer))
+	srv.Config.WriteTimeout = cfg.WriteTimeout
+	srv.Start()
+	defer srv.Close()
+
+	var got errorBody
+	resp := post(t, srv.URL+"/uppercase/batch", nil, batchRequest{S: slices.Repeat([]string{"a"}, 50)}, &got)
+
+	if resp.StatusCode != http.StatusGatewayTimeout || got.Error.Code != "deadline_exceeded" {
+		t.Errorf("Expected 504 deadline_exceeded, got %d %+v", resp.StatusCode, got)
+	}
+}
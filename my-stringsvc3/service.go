@@ -1,29 +1,96 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 )
 
 type StringService interface {
-	Uppercase(string) (string, error)
-	Count(string) int
+	Uppercase(ctx context.Context, s string, locale string) (string, error)
+	// Count fails only where it is served remotely.
+	Count(ctx context.Context, s string, mode CountMode) (int, error)
+}
+
+// CountMode selects what Count counts.
+type CountMode string
+
+const (
+	CountBytes     CountMode = "bytes"
+	CountRunes     CountMode = "runes"
+	CountGraphemes CountMode = "graphemes" // user-perceived characters: "é" and "👍🏽" count 1
+	CountWords     CountMode = "words"     // Unicode word boundaries, ignoring spaces and punctuation
+)
+
+// ParseCountMode maps "" to CountBytes, which is what Count always counted.
+func ParseCountMode(s string) (CountMode, error) {
+	switch mode := CountMode(s); mode {
+	case "":
+		return CountBytes, nil
+	case CountBytes, CountRunes, CountGraphemes, CountWords:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrCountMode, s)
+	}
 }
 
 type stringService struct{}
 
-func (stringService) Uppercase(s string) (string, error) {
+// Uppercase maps s with the rules of locale, a BCP 47 tag such as "tr" for
+// Turkish dotted İ. An empty locale keeps the language-neutral strings.ToUpper.
+func (stringService) Uppercase(_ context.Context, s string, locale string) (string, error) {
 	if s == "" {
 		return "", ErrEmpty
 	}
 
-	return strings.ToUpper(s), nil
+	if locale == "" {
+		return strings.ToUpper(s), nil
+	}
+
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrLocale, locale)
+	}
+
+	return cases.Upper(tag).String(s), nil
+}
+
+func (stringService) Count(_ context.Context, s string, mode CountMode) (int, error) {
+	switch mode {
+	case CountRunes:
+		return utf8.RuneCountInString(s), nil
+	case CountGraphemes:
+		return uniseg.GraphemeClusterCount(s), nil
+	case CountWords:
+		return wordCount(s), nil
+	default:
+		return len(s), nil
+	}
 }
 
-func (stringService) Count(s string) int {
-	return len(s)
+func wordCount(s string) int {
+	n, state := 0, -1
+	for len(s) > 0 {
+		var word string
+		word, s, state = uniseg.FirstWordInString(s, state)
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0 {
+			n++
+		}
+	}
+	return n
 }
 
-var ErrEmpty = errors.New("empty string")
+var (
+	ErrEmpty     = errors.New("empty string")
+	ErrCountMode = errors.New("unknown count mode")
+	ErrLocale    = errors.New("unknown locale")
+)
 
 type ServiceMiddleware func(StringService) StringService
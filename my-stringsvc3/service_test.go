@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestStringService_Count(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		mode CountMode
+		want int
+	}{
+		{"bytes by default", "日本語", CountBytes, 9},
+		{"runes", "日本語", CountRunes, 3},
+		{"graphemes join combining marks", "é", CountGraphemes, 1},
+		{"graphemes join emoji modifiers", "👍🏽", CountGraphemes, 1},
+		{"words skip punctuation", "Hello, world! 42 times.", CountWords, 4},
+		{"words in scripts without spaces", "日本語", CountWords, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := (stringService{}).Count(context.Background(), tt.s, tt.mode); got != tt.want || err != nil {
+				t.Errorf("Expected %d, got %d, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestParseCountMode(t *testing.T) {
+	if mode, err := ParseCountMode(""); mode != CountBytes || err != nil {
+		t.Errorf("Expected bytes for an empty mode, got %q, %v", mode, err)
+	}
+	if _, err := ParseCountMode("lines"); !errors.Is(err, ErrCountMode) {
+		t.Errorf("Expected ErrCountMode, got %v", err)
+	}
+}
+
+func TestStringService_Uppercase(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		locale  string
+		want    string
+		wantErr error
+	}{
+		{"language-neutral by default", "istanbul", "", "ISTANBUL", nil},
+		{"turkish dotted i", "istanbul", "tr", "İSTANBUL", nil},
+		{"german sharp s", "straße", "de", "STRASSE", nil},
+		{"empty string", "", "", "", ErrEmpty},
+		{"malformed locale", "abc", "not a tag", "", ErrLocale},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (stringService{}).Uppercase(context.Background(), tt.s, tt.locale)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %q, %v, got %q, %v", tt.want, tt.wantErr, got, err)
+			}
+		})
+	}
+}
+
+func TestCountHTTP(t *testing.T) {
+	front := newFrontend(t, stringService{})
+
+	t.Run("counts bytes without a mode", func(t *testing.T) {
+		var got countResponse
+		post(t, front.URL+"/count", nil, map[string]string{"s": "日本語"}, &got)
+		if got.V != 9 {
+			t.Errorf("Expected 9 bytes, got %d", got.V)
+		}
+	})
+
+	t.Run("counts in the requested mode", func(t *testing.T) {
+		var got countResponse
+		post(t, front.URL+"/count", nil, countRequest{S: "日本語", Mode: "graphemes"}, &got)
+		if got.V != 3 {
+			t.Errorf("Expected 3 graphemes, got %d", got.V)
+		}
+	})
+
+	t.Run("rejects unknown modes", func(t *testing.T) {
+		var got errorBody
+		resp := post(t, front.URL+"/count", nil, countRequest{S: "abc", Mode: "lines"}, &got)
+		if resp.StatusCode != http.StatusBadRequest || got.Error.Code != "unknown_count_mode" {
+			t.Errorf("Expected 400 unknown_count_mode, got %d %+v", resp.StatusCode, got)
+		}
+	})
+}
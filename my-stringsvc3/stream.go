@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// maxStreamLine bounds one line of a stream.
+const maxStreamLine = 1 << 20
+
+// streamRequest is one line of a stream: a method, its string and options.
+type streamRequest struct {
+	Method string `json:"method"`
+	S      string `json:"s"`
+	itemOptions
+}
+
+var errUnknownMethod = errors.New("unknown method")
+
+// streamHandler serves newline-delimited JSON: each request line such as
+// {"method":"count","s":"abc"} is answered by one response line, in order,
+// before the next line is read. A client that stops reading responses thus
+// stops the server from reading further requests.
+//
+// The server's read and write timeouts apply to each line rather than to the
+// whole stream, so only a stream that stalls times out. So does the caller's
+// X-Request-Timeout: each line gets its full duration.
+type streamHandler struct {
+	endpoints    map[string]endpoint.Endpoint
+	newRequests  map[string]func(s string, opts itemOptions) interface{}
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+func newStreamHandler(uppercase, count endpoint.Endpoint, readTimeout, writeTimeout time.Duration) http.Handler {
+	return streamHandler{
+		endpoints: map[string]endpoint.Endpoint{
+			"uppercase": uppercase,
+			"count":     count,
+		},
+		newRequests: map[string]func(s string, opts itemOptions) interface{}{
+			"uppercase": newUppercaseRequest,
+			"count":     newCountRequest,
+		},
+		readTimeout:  readTimeout,
+		writeTimeout: writeTimeout,
+	}
+}
+
+func (h streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// answer lines while the request body is still arriving
+	rc := http.NewResponseController(w)
+	rc.EnableFullDuplex()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	ctx := timeoutFromHTTP(r.Context(), r)
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	enc := json.NewEncoder(w)
+
+	for h.extendDeadlines(rc); scanner.Scan(); h.extendDeadlines(rc) {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := enc.Encode(h.serveLine(ctx, scanner.Bytes())); err != nil {
+			return // client gone
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		enc.Encode(itemResult(failure{badRequestError{err}}))
+	}
+}
+
+// extendDeadlines gives the next line the full timeouts; zero means none.
+func (h streamHandler) extendDeadlines(rc *http.ResponseController) {
+	rc.SetReadDeadline(deadline(h.readTimeout))
+	rc.SetWriteDeadline(deadline(h.writeTimeout))
+}
+
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+func (h streamHandler) serveLine(ctx context.Context, line []byte) interface{} {
+	var req streamRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return itemResult(failure{badRequestError{err}})
+	}
+
+	e, ok := h.endpoints[req.Method]
+	if !ok {
+		return itemResult(failure{fmt.Errorf("%w: %q", errUnknownMethod, req.Method)})
+	}
+
+	response, err := e(ctx, h.newRequests[req.Method](req.S, req.itemOptions))
+	if err != nil {
+		return itemResult(failure{err})
+	}
+	return itemResult(response)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	front := newFrontend(t, stringService{})
+
+	t.Run("keeps item errors in their results", func(t *testing.T) {
+		var got struct {
+			Results []json.RawMessage `json:"results"`
+		}
+		resp := post(t, front.URL+"/uppercase/batch", nil, batchRequest{S: []string{"a", "", "b"}}, &got)
+
+		want := []string{`{"v":"A"}`, `{"error":{"code":"empty_string","message":"empty string"}}`, `{"v":"B"}`}
+		if resp.StatusCode != http.StatusOK || len(got.Results) != len(want) {
+			t.Fatalf("Expected 200 with %d results, got %d %s", len(want), resp.StatusCode, got.Results)
+		}
+		for i := range want {
+			if string(got.Results[i]) != want[i] {
+				t.Errorf("Expected result %d to be %s, got %s", i, want[i], got.Results[i])
+			}
+		}
+	})
+
+	t.Run("applies options to every item", func(t *testing.T) {
+		var got struct {
+			Results []countResponse `json:"results"`
+		}
+		post(t, front.URL+"/count/batch", nil, batchRequest{S: []string{"日本語", "ab"}, itemOptions: itemOptions{Mode: "runes"}}, &got)
+
+		if len(got.Results) != 2 || got.Results[0].V != 3 || got.Results[1].V != 2 {
+			t.Errorf("Expected rune counts [3 2], got %+v", got.Results)
+		}
+	})
+
+	t.Run("rejects oversized batches", func(t *testing.T) {
+		var got errorBody
+		resp := post(t, front.URL+"/count/batch", nil, batchRequest{S: make([]string, maxBatchSize+1)}, &got)
+
+		if resp.StatusCode != http.StatusBadRequest || got.Error.Code != "batch_too_large" {
+			t.Errorf("Expected 400 batch_too_large, got %d %+v", resp.StatusCode, got)
+		}
+	})
+
+	t.Run("goes on past items whose endpoint fails", func(t *testing.T) {
+		item := func(_ context.Context, request interface{}) (interface{}, error) {
+			if request.(uppercaseRequest).S == "down" {
+				return nil, upstreamError{errors.New("connection refused")}
+			}
+			return uppercaseResponse{V: "OK"}, nil
+		}
+
+		response, err := makeBatchEndpoint(item, newUppercaseRequest)(context.Background(), batchRequest{S: []string{"down", "up"}})
+
+		if err != nil {
+			t.Fatalf("Expected the batch to succeed, got %v", err)
+		}
+		results := response.(batchResponse).Results
+		if got, ok := results[0].(errorBody); !ok || got.Error.Code != "upstream_error" {
+			t.Errorf("Expected upstream_error for the first item, got %+v", results[0])
+		}
+		if got := results[1]; !reflect.DeepEqual(got, uppercaseResponse{V: "OK"}) {
+			t.Errorf("Expected the second item to be served, got %+v", got)
+		}
+	})
+}
+
+func TestStream(t *testing.T) {
+	front := newFrontend(t, stringService{})
+
+	t.Run("answers each line in order", func(t *testing.T) {
+		body := strings.Join([]string{
+			`{"method":"uppercase","s":"hello"}`,
+			``,
+			`{"method":"count","s":"日本語","mode":"runes"}`,
+			`{"method":"reverse","s":"abc"}`,
+			`not json`,
+			`{"method":"uppercase","s":""}`,
+		}, "\n")
+		resp, err := http.Post(front.URL+"/stream", "application/x-ndjson", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Expected stream to open, got %v", err)
+		}
+		defer resp.Body.Close()
+		got, _ := io.ReadAll(resp.Body)
+
+		want := []string{
+			`{"v":"HELLO"}`,
+			`{"v":3}`,
+			`{"error":{"code":"unknown_method","message":"unknown method: \"reverse\""}}`,
+			`"code":"bad_request"`,
+			`{"error":{"code":"empty_string","message":"empty string"}}`,
+		}
+		lines := strings.Split(strings.TrimSpace(string(got)), "\n")
+		if len(lines) != len(want) {
+			t.Fatalf("Expected %d lines, got %q", len(want), lines)
+		}
+		for i := range want {
+			if !strings.Contains(lines[i], want[i]) {
+				t.Errorf("Expected line %d to contain %s, got %s", i, want[i], lines[i])
+			}
+		}
+	})
+
+	t.Run("answers a line before the next is sent", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		req, _ := http.NewRequest("POST", front.URL+"/stream", pr)
+		done := make(chan *http.Response, 1)
+		go func() {
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("Expected stream to open, got %v", err)
+				close(done)
+				return
+			}
+			done <- resp
+		}()
+
+		fmt.Fprintln(pw, `{"method":"uppercase","s":"one"}`)
+		var resp *http.Response
+		select {
+		case resp = <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected the response to start while the request is open")
+		}
+		if resp == nil {
+			return
+		}
+		defer resp.Body.Close()
+		lines := bufio.NewScanner(resp.Body)
+
+		for _, s := range []string{"one", "two"} {
+			if s != "one" {
+				fmt.Fprintf(pw, "{\"method\":\"uppercase\",\"s\":%q}\n", s)
+			}
+			if !lines.Scan() {
+				t.Fatalf("Expected a line for %s, got %v", s, lines.Err())
+			}
+			if want := fmt.Sprintf(`{"v":%q}`, strings.ToUpper(s)); lines.Text() != want {
+				t.Errorf("Expected %s, got %s", want, lines.Text())
+			}
+		}
+	})
+}
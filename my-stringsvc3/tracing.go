@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/go-kit/kit/endpoint"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc/metadata"
+)
+
+const tracerName = "github.com/shiiyan/learn-go/my-stringsvc3"
+
+// newTracerProvider returns the provider selected by -trace: "stdout" writes
+// finished spans to stdout as JSON, "" records nothing. instance tells the
+// hops of a chain apart. Shutdown flushes spans not yet written.
+//
+// The W3C trace context propagator is installed either way, so an instance
+// that doesn't record still passes its callers' traces on.
+func newTracerProvider(exporter, instance string) (trace.TracerProvider, func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	switch exporter {
+	case "":
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	case "stdout":
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, nil, err
+		}
+		tp := sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exp),
+			sdktrace.WithResource(resource.NewSchemaless(
+				semconv.ServiceName("my-stringsvc3"),
+				semconv.ServiceInstanceID(instance),
+			)),
+		)
+		return tp, tp.Shutdown, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q, want stdout or empty for none", exporter)
+	}
+}
+
+// endSpan ends span, marking it failed if err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingMiddleware spans every call into next, named after the layer it
+// wraps, so a trace shows how long each middleware took.
+type tracingMiddleware struct {
+	tracer trace.Tracer
+	layer  string
+	next   StringService
+}
+
+func tracing(tracer trace.Tracer, layer string) ServiceMiddleware {
+	return func(next StringService) StringService {
+		return tracingMiddleware{tracer, layer, next}
+	}
+}
+
+func (mw tracingMiddleware) Uppercase(ctx context.Context, s string, locale string) (output string, err error) {
+	ctx, span := mw.tracer.Start(ctx, mw.layer+".Uppercase")
+	defer func() { endSpan(span, err) }()
+
+	return mw.next.Uppercase(ctx, s, locale)
+}
+
+func (mw tracingMiddleware) Count(ctx context.Context, s string, mode CountMode) (n int, err error) {
+	ctx, span := mw.tracer.Start(ctx, mw.layer+".Count", trace.WithAttributes(attribute.String("mode", string(mode))))
+	defer func() { endSpan(span, err) }()
+
+	return mw.next.Count(ctx, s, mode)
+}
+
+// traceHandler spans each request to h as a server span, continuing the
+// caller's trace if the request carries one.
+func traceHandler(tracer trace.Tracer, route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.HTTPRoute(route)),
+		)
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.status))
+		if sw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+	})
+}
+
+// statusWriter remembers the status code written through it. Unwrap lets
+// http.ResponseController reach the flusher underneath.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// traceEndpoint spans each call of an endpoint.
+func traceEndpoint(tracer trace.Tracer, name string, opts ...trace.SpanStartOption) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			ctx, span := tracer.Start(ctx, name, opts...)
+			defer func() { endSpan(span, err) }()
+
+			return next(ctx, request)
+		}
+	}
+}
+
+type attemptsContextKey struct{}
+
+// traceRetries spans a proxied call across all its attempts and numbers the
+// attempts, which traceAttempt spans one by one.
+func traceRetries(tracer trace.Tracer, method string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			attempts := new(atomic.Int32)
+			ctx = context.WithValue(ctx, attemptsContextKey{}, attempts)
+			ctx, span := tracer.Start(ctx, "proxy."+method)
+			defer func() {
+				span.SetAttributes(attribute.Int("attempts", int(attempts.Load())))
+				endSpan(span, err)
+			}()
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// traceAttempt spans one attempt at an upstream instance. Requests the
+// instance's limiter or breaker turn away show up as failed attempts without
+// the client span of the call itself.
+func traceAttempt(tracer trace.Tracer, method, instance string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			attempt := 1
+			if attempts, ok := ctx.Value(attemptsContextKey{}).(*atomic.Int32); ok {
+				attempt = int(attempts.Add(1))
+			}
+			ctx, span := tracer.Start(ctx, "proxy."+method+".attempt",
+				trace.WithAttributes(attribute.String("instance", instance), attribute.Int("attempt", attempt)),
+			)
+			defer func() { endSpan(span, err) }()
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// traceToHTTP is a go-kit ClientBefore hook sending ctx's trace upstream.
+func traceToHTTP(ctx context.Context, r *http.Request) context.Context {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+	return ctx
+}
+
+// traceToGRPC and traceFromGRPC carry the trace in gRPC metadata.
+func traceToGRPC(ctx context.Context, md *metadata.MD) context.Context {
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(*md))
+	return ctx
+}
+
+func traceFromGRPC(ctx context.Context, md metadata.MD) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+}
+
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) { metadata.MD(c).Set(key, value) }
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
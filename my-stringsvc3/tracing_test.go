@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spansByName waits for the span named last to end, then returns the
+// exported spans by name.
+func spansByName(t *testing.T, exporter *tracetest.InMemoryExporter, last string) map[string][]tracetest.SpanStub {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		spans := map[string][]tracetest.SpanStub{}
+		for _, s := range exporter.GetSpans() {
+			spans[s.Name] = append(spans[s.Name], s)
+		}
+		if len(spans[last]) > 0 {
+			return spans
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected span %s, got %v", last, spans)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTracing_TwoHops(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(t.Context())
+	tracer := tp.Tracer(tracerName)
+
+	// the upstream fails its first request, so the call takes two attempts
+	var hits atomic.Int32
+	h := makeHTTPHandler(tracing(tracer, "service")(stringService{}), defaultServerConfig(), tracer)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			http.Error(w, "warming up", http.StatusServiceUnavailable)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer upstream.Close()
+
+	var svc StringService = stringService{}
+	svc = proxyingMiddleware(sd.FixedInstancer{upstream.URL}, defaultRoutes, testProxyConfig(), discardBreakerMetrics(), newUpstreamBreakers(), tracer, log.NewNopLogger())(svc)
+	svc = tracing(tracer, "proxying")(svc)
+	front := httptest.NewServer(makeHTTPHandler(svc, defaultServerConfig(), tracer))
+	defer front.Close()
+
+	var got uppercaseResponse
+	post(t, front.URL+"/uppercase", nil, uppercaseRequest{S: "hello"}, &got)
+	if got.V != "HELLO" {
+		t.Fatalf("Expected HELLO, got %+v", got)
+	}
+
+	spans := spansByName(t, exporter, "POST /uppercase")
+	for name, n := range map[string]int{
+		"POST /uppercase":         1, // frontend server
+		"proxying.Uppercase":      1, // frontend middleware layer
+		"proxy.uppercase":         1, // all attempts
+		"proxy.uppercase.attempt": 2,
+		"proxy.uppercase.call":    2, // outbound client
+		"service.Uppercase":       1, // upstream middleware layer
+	} {
+		if len(spans[name]) != n {
+			t.Errorf("Expected %d %s spans, got %d", n, name, len(spans[name]))
+		}
+	}
+
+	traceID := spans["POST /uppercase"][0].SpanContext.TraceID()
+	for name, ss := range spans {
+		for _, s := range ss {
+			if s.SpanContext.TraceID() != traceID {
+				t.Errorf("Expected %s in trace %s, got %s", name, traceID, s.SpanContext.TraceID())
+			}
+		}
+	}
+
+	// the upstream server span continues the outbound call that made it
+	calls := map[trace.SpanID]bool{}
+	for _, s := range spans["proxy.uppercase.call"] {
+		if s.SpanKind != trace.SpanKindClient {
+			t.Errorf("Expected a client span for the outbound call, got %v", s.SpanKind)
+		}
+		calls[s.SpanContext.SpanID()] = true
+	}
+	upstreamServer := spans["GET /uppercase"]
+	if len(upstreamServer) != 1 {
+		t.Fatalf("Expected 1 upstream server span, got %d", len(upstreamServer))
+	}
+	if s := upstreamServer[0]; s.SpanKind != trace.SpanKindServer || !calls[s.Parent.SpanID()] {
+		t.Errorf("Expected upstream server span to be a child of an outbound call, got %v with parent %s", s.SpanKind, s.Parent.SpanID())
+	}
+}
@@ -4,53 +4,217 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// makeHTTPHandler serves the StringService methods, singly, in batches and
+// as a stream. cfg's timeouts bound the stream, which extends its deadlines
+// as it goes, and batches, which must finish before the write timeout.
+func makeHTTPHandler(svc StringService, cfg serverConfig, tracer trace.Tracer) *http.ServeMux {
+	options := []httptransport.ServerOption{
+		httptransport.ServerBefore(timeoutFromHTTP),
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	uppercase := makeUppercaseEndpoint(svc)
+	count := makeCountEndpoint(svc)
+
+	uppercaseHandler := httptransport.NewServer(
+		withTimeout(uppercase),
+		decodeUppercaseRequest,
+		encodeResponse,
+		options...,
+	)
+	countHandler := httptransport.NewServer(
+		withTimeout(count),
+		decodeCountRequest,
+		encodeResponse,
+		options...,
+	)
+	uppercaseBatchHandler := httptransport.NewServer(
+		withTimeout(makeBatchEndpoint(uppercase, newUppercaseRequest)),
+		decodeBatchRequest,
+		encodeResponse,
+		options...,
+	)
+	countBatchHandler := httptransport.NewServer(
+		withTimeout(makeBatchEndpoint(count, newCountRequest)),
+		decodeBatchRequest,
+		encodeResponse,
+		options...,
+	)
+
+	mux := http.NewServeMux()
+	handle := func(route string, h http.Handler) {
+		mux.Handle(route, traceHandler(tracer, route, h))
+	}
+	handle("/uppercase", uppercaseHandler)
+	handle("/count", countHandler)
+	handle("/uppercase/batch", beforeWriteTimeout(cfg.WriteTimeout, uppercaseBatchHandler))
+	handle("/count/batch", beforeWriteTimeout(cfg.WriteTimeout, countBatchHandler))
+	handle("/stream", newStreamHandler(withTimeout(uppercase), withTimeout(count), cfg.ReadTimeout, cfg.WriteTimeout))
+	return mux
+}
+
+// beforeWriteTimeout ends h's requests a tenth of writeTimeout before the
+// server would cut the connection, leaving that time to write the response.
+// A batch too slow to finish thus fails with a 504 rather than an EOF.
+func beforeWriteTimeout(writeTimeout time.Duration, h http.Handler) http.Handler {
+	if writeTimeout <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), writeTimeout-writeTimeout/10)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// timeoutHeader carries the time a caller has left for a request to the next
+// hop. It is sent as a duration rather than a deadline so clock skew between
+// hosts does not matter.
+const timeoutHeader = "X-Request-Timeout"
+
+type timeoutContextKey struct{}
+
+// timeoutToHTTP is a go-kit ClientBefore hook sending the remaining time of
+// ctx's deadline upstream.
+func timeoutToHTTP(ctx context.Context, r *http.Request) context.Context {
+	if deadline, ok := ctx.Deadline(); ok {
+		r.Header.Set(timeoutHeader, time.Until(deadline).String())
+	}
+	return ctx
+}
+
+// timeoutFromHTTP is a go-kit ServerBefore hook reading the caller's timeout.
+// withTimeout applies it, since a RequestFunc cannot cancel what it creates.
+func timeoutFromHTTP(ctx context.Context, r *http.Request) context.Context {
+	if d, err := time.ParseDuration(r.Header.Get(timeoutHeader)); err == nil {
+		return context.WithValue(ctx, timeoutContextKey{}, d)
+	}
+	return ctx
+}
+
+func withTimeout(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		d, ok := ctx.Value(timeoutContextKey{}).(time.Duration)
+		if !ok {
+			return next(ctx, request)
+		}
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return next(ctx, request)
+	}
+}
+
 type uppercaseRequest struct {
-	S string `json:"s"`
+	S      string `json:"s"`
+	Locale string `json:"locale,omitempty"`
 }
 
 type uppercaseResponse struct {
-	V   string `json:"v"`
-	Err string `json:"err,omitempty"`
+	V string `json:"v"`
+	failure
 }
 
 type countRequest struct {
-	S string `json:"s"`
+	S    string `json:"s"`
+	Mode string `json:"mode,omitempty"`
 }
 
 type countResponse struct {
 	V int `json:"v"`
+	failure
 }
 
 func makeUppercaseEndpoint(svc StringService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (interface{}, error) {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(uppercaseRequest)
-		v, err := svc.Uppercase(req.S)
+		v, err := svc.Uppercase(ctx, req.S, req.Locale)
+		return uppercaseResponse{v, failure{err}}, nil
+	}
+}
+
+func makeCountEndpoint(svc StringService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(countRequest)
+		mode, err := ParseCountMode(req.Mode)
 		if err != nil {
-			return uppercaseResponse{v, err.Error()}, nil
+			return countResponse{0, failure{err}}, nil
 		}
 
-		return uppercaseResponse{v, ""}, nil
+		v, err := svc.Count(ctx, req.S, mode)
+		return countResponse{v, failure{err}}, nil
 	}
 }
 
-func makeCountEndpoint(svc StringService) endpoint.Endpoint {
-	return func(_ context.Context, request interface{}) (interface{}, error) {
-		req := request.(countRequest)
-		v := svc.Count(req.S)
-		return countResponse{v}, nil
+// maxBatchSize bounds the strings of one batch request.
+const maxBatchSize = 1000
+
+var errBatchTooLarge = fmt.Errorf("batch exceeds %d strings", maxBatchSize)
+
+// itemOptions apply to every string of a batch, or to one line of a stream.
+type itemOptions struct {
+	Locale string `json:"locale,omitempty"`
+	Mode   string `json:"mode,omitempty"`
+}
+
+type batchRequest struct {
+	S []string `json:"s"`
+	itemOptions
+}
+
+// batchResponse holds one item result per string, in order, unless the batch
+// as a whole failed.
+type batchResponse struct {
+	Results []interface{} `json:"results"`
+	failure
+}
+
+// makeBatchEndpoint applies the item endpoint to every string of a batch.
+// Errors of an item, whether service errors such as ErrEmpty or failures to
+// reach an upstream, stay in that item's result and the batch goes on.
+func makeBatchEndpoint(item endpoint.Endpoint, newRequest func(s string, opts itemOptions) interface{}) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(batchRequest)
+		if len(req.S) > maxBatchSize {
+			return batchResponse{nil, failure{errBatchTooLarge}}, nil
+		}
+
+		results := make([]interface{}, 0, len(req.S))
+		for _, s := range req.S {
+			if err := ctx.Err(); err != nil {
+				return batchResponse{nil, failure{err}}, nil
+			}
+			response, err := item(ctx, newRequest(s, req.itemOptions))
+			if err != nil {
+				response = failure{err}
+			}
+			results = append(results, itemResult(response))
+		}
+		return batchResponse{results, failure{}}, nil
 	}
 }
 
+func newUppercaseRequest(s string, opts itemOptions) interface{} {
+	return uppercaseRequest{S: s, Locale: opts.Locale}
+}
+
+func newCountRequest(s string, opts itemOptions) interface{} {
+	return countRequest{S: s, Mode: opts.Mode}
+}
+
 func decodeUppercaseRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var request uppercaseRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
+		return nil, badRequestError{err}
 	}
 
 	return request, nil
@@ -59,13 +223,29 @@ func decodeUppercaseRequest(_ context.Context, r *http.Request) (interface{}, er
 func decodeCountRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var request countRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
+		return nil, badRequestError{err}
+	}
+
+	return request, nil
+}
+
+func decodeBatchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, badRequestError{err}
 	}
 
 	return request, nil
 }
 
-func encodeResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+// encodeResponse writes failed responses through encodeError, so service
+// errors get their status code and envelope too.
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if f, ok := response.(endpoint.Failer); ok && f.Failed() != nil {
+		encodeError(ctx, f.Failed(), w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	return json.NewEncoder(w).Encode(response)
 }
 
@@ -78,8 +258,19 @@ func encodeRequest(_ context.Context, r *http.Request, request interface{}) erro
 	return nil
 }
 
-func decodeUppercaseResponse(_ context.Context, r *http.Response) (interface{}, error) {
-	var response uppercaseResponse
+// decodeResponse decodes T, or for error responses the service error into T,
+// which must embed failure.
+func decodeResponse[T any](_ context.Context, r *http.Response) (interface{}, error) {
+	var response T
+	if r.StatusCode >= http.StatusBadRequest {
+		serviceErr, err := decodeError(r)
+		if err != nil {
+			return nil, err
+		}
+		any(&response).(interface{ fail(error) }).fail(serviceErr)
+		return response, nil
+	}
+
 	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
 		return nil, err
 	}
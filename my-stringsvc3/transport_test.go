@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+)
+
+// newFrontend serves svc over HTTP, as the instance clients talk to.
+func newFrontend(t *testing.T, svc StringService) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(makeHTTPHandler(svc, defaultServerConfig(), noopTracer))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// post sends body as JSON with header, and decodes the response into out
+// unless out is nil.
+func post(t *testing.T, url string, header http.Header, body interface{}, out interface{}) *http.Response {
+	t.Helper()
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(body)
+	req, _ := http.NewRequest("POST", url, &buf)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected POST %s to succeed, got %v", url, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("Expected JSON from %s, got %v", url, err)
+		}
+	}
+	return resp
+}
+
+func TestTimeoutPropagation(t *testing.T) {
+	t.Run("passes the remaining time upstream", func(t *testing.T) {
+		received := make(chan string, 1)
+		h := makeHTTPHandler(stringService{}, defaultServerConfig(), noopTracer)
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received <- r.Header.Get(timeoutHeader)
+			h.ServeHTTP(w, r)
+		}))
+		defer upstream.Close()
+		svc, _ := newTestProxy(t, sd.FixedInstancer{upstream.URL}, defaultRoutes, testProxyConfig(), discardBreakerMetrics())
+		front := newFrontend(t, svc)
+
+		var got uppercaseResponse
+		post(t, front.URL+"/uppercase", http.Header{timeoutHeader: {"500ms"}}, uppercaseRequest{S: "hello"}, &got)
+
+		if got.V != "HELLO" {
+			t.Errorf("Expected HELLO, got %+v", got)
+		}
+		d, err := time.ParseDuration(<-received)
+		if err != nil || d <= 0 || d > 500*time.Millisecond {
+			t.Errorf("Expected upstream timeout within 500ms, got %v, %v", d, err)
+		}
+	})
+
+	t.Run("answers 504 when the caller's time runs out", func(t *testing.T) {
+		release := make(chan struct{})
+		upstream := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			<-release
+		}))
+		defer upstream.Close()
+		defer close(release)
+		svc, _ := newTestProxy(t, sd.FixedInstancer{upstream.URL}, defaultRoutes, testProxyConfig(), discardBreakerMetrics())
+		front := newFrontend(t, svc)
+
+		var got errorBody
+		start := time.Now()
+		resp := post(t, front.URL+"/uppercase", http.Header{timeoutHeader: {"50ms"}}, uppercaseRequest{S: "hello"}, &got)
+
+		if resp.StatusCode != http.StatusGatewayTimeout || got.Error.Code != "deadline_exceeded" {
+			t.Errorf("Expected 504 deadline_exceeded, got %d %+v", resp.StatusCode, got)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Expected to give up after 50ms, took %v", elapsed)
+		}
+	})
+
+	t.Run("bounds each stream line", func(t *testing.T) {
+		release := make(chan struct{})
+		upstream := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			<-release
+		}))
+		defer upstream.Close()
+		defer close(release)
+		svc, _ := newTestProxy(t, sd.FixedInstancer{upstream.URL}, defaultRoutes, testProxyConfig(), discardBreakerMetrics())
+		front := newFrontend(t, svc)
+
+		body := strings.NewReader(`{"method":"uppercase","s":"a"}` + "\n" + `{"method":"uppercase","s":"b"}` + "\n")
+		req, _ := http.NewRequest("POST", front.URL+"/stream", body)
+		req.Header.Set(timeoutHeader, "50ms")
+		start := time.Now()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected the stream to be answered, got %v", err)
+		}
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for i := range 2 {
+			var got errorBody
+			if err := dec.Decode(&got); err != nil || got.Error.Code != "deadline_exceeded" {
+				t.Errorf("Expected deadline_exceeded for line %d, got %+v, %v", i, got, err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Expected each line to give up after 50ms, took %v", elapsed)
+		}
+	})
+
+	t.Run("leaves requests without a timeout unbounded", func(t *testing.T) {
+		ctx := timeoutFromHTTP(context.Background(), httptest.NewRequest("POST", "/uppercase", nil))
+		withTimeout(func(ctx context.Context, _ interface{}) (interface{}, error) {
+			if _, ok := ctx.Deadline(); ok {
+				t.Errorf("Expected no deadline")
+			}
+			return nil, nil
+		})(ctx, nil)
+	})
+}
@@ -0,0 +1,267 @@
+package policyrulemodeling
+
+import (
+	"fmt"
+	"slices"
+)
+
+type FindingKind string
+
+const (
+	FindingUnreachable   FindingKind = "unreachable"
+	FindingShadowed      FindingKind = "shadowed"
+	FindingConflict      FindingKind = "conflict"
+	FindingNoDefaultDeny FindingKind = "no-default-deny"
+	FindingDenyAll       FindingKind = "deny-all"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+type Finding struct {
+	PolicyID string      `json:"policy_id"`
+	RuleID   string      `json:"rule_id,omitempty"`
+	Kind     FindingKind `json:"kind"`
+	Severity Severity    `json:"severity"`
+	Message  string      `json:"message"`
+}
+
+func (f Finding) String() string {
+	if f.RuleID == "" {
+		return fmt.Sprintf("%s: %s [%s] %s", f.Severity, f.PolicyID, f.Kind, f.Message)
+	}
+	return fmt.Sprintf("%s: %s/%s [%s] %s", f.Severity, f.PolicyID, f.RuleID, f.Kind, f.Message)
+}
+
+func AnalyzeBundle(b *Bundle) []Finding {
+	var findings []Finding
+	for _, spec := range b.Policies {
+		findings = append(findings, AnalyzePolicy(spec)...)
+	}
+	return findings
+}
+
+// AnalyzePolicy reports problems that can be proven from the rules alone.
+// Conditions using ValueFrom depend on runtime values, so they only count as
+// equal to an identical condition and never as contradictory.
+func AnalyzePolicy(spec PolicySpec) []Finding {
+	var findings []Finding
+	report := func(rule *AttributeRule, kind FindingKind, severity Severity, format string, args ...interface{}) {
+		f := Finding{PolicyID: spec.ID, Kind: kind, Severity: severity, Message: fmt.Sprintf(format, args...)}
+		if rule != nil {
+			f.RuleID = rule.ID
+		}
+		findings = append(findings, f)
+	}
+
+	firstMatch := spec.Combining != CombiningAllMustAllow
+	unreachable := map[*AttributeRule]bool{}
+
+	for j, rule := range spec.Rules {
+		if reason, ok := contradiction(rule); ok {
+			report(rule, FindingUnreachable, SeverityError, "rule can never match: %s", reason)
+			unreachable[rule] = true
+			continue
+		}
+		if !firstMatch {
+			continue
+		}
+		for _, earlier := range spec.Rules[:j] {
+			if unreachable[earlier] || !covers(earlier, rule) {
+				continue
+			}
+			if earlier.RuleEffect != rule.RuleEffect {
+				report(rule, FindingShadowed, SeverityError, "%s rule is fully shadowed by earlier %s rule %s", rule.RuleEffect, earlier.RuleEffect, earlier.ID)
+			} else {
+				report(rule, FindingShadowed, SeverityWarning, "rule is redundant, earlier rule %s already matches", earlier.ID)
+			}
+			unreachable[rule] = true
+			break
+		}
+	}
+
+	for j, rule := range spec.Rules {
+		// a trailing catch-all is the intended fallback, not a conflict
+		if unreachable[rule] || (firstMatch && isCatchAll(rule)) {
+			continue
+		}
+		for _, earlier := range spec.Rules[:j] {
+			if unreachable[earlier] || earlier.RuleEffect == rule.RuleEffect || disjoint(earlier, rule) {
+				continue
+			}
+			if firstMatch {
+				report(rule, FindingConflict, SeverityWarning, "overlaps %s rule %s; rule order decides the outcome", earlier.RuleEffect, earlier.ID)
+			} else {
+				report(rule, FindingConflict, SeverityWarning, "overlaps %s rule %s; deny wins where both match", earlier.RuleEffect, earlier.ID)
+			}
+		}
+	}
+
+	catchAll := slices.IndexFunc(spec.Rules, func(r *AttributeRule) bool {
+		return r.RuleEffect == EffectDeny && isCatchAll(r)
+	})
+	switch {
+	case firstMatch && catchAll < 0:
+		report(nil, FindingNoDefaultDeny, SeverityWarning, "policy has no unconditional deny rule")
+	case !firstMatch && catchAll >= 0:
+		report(spec.Rules[catchAll], FindingDenyAll, SeverityError, "unconditional deny rule makes the all-must-allow policy deny every request")
+	}
+
+	return findings
+}
+
+func isCatchAll(r *AttributeRule) bool {
+	return len(r.Actions) == 0 && len(r.ResourceTypes) == 0 && len(r.Conditions) == 0
+}
+
+// contradiction reports why the rule's own conditions can never hold together.
+func contradiction(r *AttributeRule) (string, bool) {
+	for i, a := range r.Conditions {
+		if a.Operator == OpIn && a.ValueFrom == "" && listLen(a.Value) == 0 {
+			return fmt.Sprintf("%q matches an empty list", a.String()), true
+		}
+		for _, b := range r.Conditions[i+1:] {
+			if conditionsDisjoint(a, b) {
+				return fmt.Sprintf("%q contradicts %q", a.String(), b.String()), true
+			}
+		}
+	}
+	return "", false
+}
+
+// covers reports whether a matches every request b matches.
+func covers(a, b *AttributeRule) bool {
+	if !listCovers(a.Actions, b.Actions) || !listCovers(a.ResourceTypes, b.ResourceTypes) {
+		return false
+	}
+	for _, want := range a.Conditions {
+		implied := slices.ContainsFunc(b.Conditions, func(have Condition) bool {
+			return conditionImplies(have, want)
+		})
+		if !implied {
+			return false
+		}
+	}
+	return true
+}
+
+// disjoint reports whether no request can match both a and b.
+func disjoint(a, b *AttributeRule) bool {
+	if listsDisjoint(a.Actions, b.Actions) || listsDisjoint(a.ResourceTypes, b.ResourceTypes) {
+		return true
+	}
+	for _, ca := range a.Conditions {
+		for _, cb := range b.Conditions {
+			if conditionsDisjoint(ca, cb) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listCovers treats an empty list as "anything".
+func listCovers(a, b []string) bool {
+	if len(a) == 0 {
+		return true
+	}
+	if len(b) == 0 {
+		return false
+	}
+	for _, v := range b {
+		if !slices.Contains(a, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func listsDisjoint(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	return !slices.ContainsFunc(a, func(v string) bool { return slices.Contains(b, v) })
+}
+
+// conditionImplies reports whether have holding guarantees want holds.
+func conditionImplies(have, want Condition) bool {
+	if have.Attribute != want.Attribute {
+		return false
+	}
+	if sameCondition(have, want) {
+		return true
+	}
+	if have.ValueFrom != "" || want.ValueFrom != "" {
+		return false
+	}
+
+	switch {
+	case want.Operator == OpExists:
+		return have.Operator == OpEquals || have.Operator == OpIn
+	case want.Operator == OpIn && have.Operator == OpEquals:
+		return containsValue(want.Value, have.Value)
+	case want.Operator == OpIn && have.Operator == OpIn:
+		return listSubset(have.Value, want.Value)
+	case want.Operator == OpNotEquals && have.Operator == OpEquals:
+		return !equalValues(have.Value, want.Value)
+	case want.Operator == OpNotEquals && have.Operator == OpIn:
+		return !containsValue(have.Value, want.Value)
+	default:
+		return false
+	}
+}
+
+// conditionsDisjoint reports whether a and b can never hold at the same time.
+func conditionsDisjoint(a, b Condition) bool {
+	if a.Attribute != b.Attribute || a.ValueFrom != "" || b.ValueFrom != "" {
+		return false
+	}
+	// order mixed pairs the way the cases below expect them
+	switch {
+	case a.Operator == OpNotEquals && b.Operator == OpEquals,
+		a.Operator == OpIn && b.Operator == OpEquals,
+		a.Operator == OpNotEquals && b.Operator == OpIn:
+		a, b = b, a
+	}
+
+	switch {
+	case a.Operator == OpEquals && b.Operator == OpEquals:
+		return !equalValues(a.Value, b.Value)
+	case a.Operator == OpEquals && b.Operator == OpNotEquals:
+		return equalValues(a.Value, b.Value)
+	case a.Operator == OpEquals && b.Operator == OpIn:
+		return !containsValue(b.Value, a.Value)
+	case a.Operator == OpIn && b.Operator == OpIn:
+		return !listsIntersect(a.Value, b.Value)
+	case a.Operator == OpIn && b.Operator == OpNotEquals:
+		return listSubset(a.Value, []interface{}{b.Value})
+	default:
+		return false
+	}
+}
+
+func sameCondition(a, b Condition) bool {
+	return a.Attribute == b.Attribute && a.Operator == b.Operator && a.ValueFrom == b.ValueFrom &&
+		(a.Operator == OpExists || a.ValueFrom != "" || equalValues(a.Value, b.Value))
+}
+
+func listLen(v interface{}) int {
+	return len(listValues(v))
+}
+
+func listSubset(sub, super interface{}) bool {
+	for _, v := range listValues(sub) {
+		if !containsValue(super, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func listsIntersect(a, b interface{}) bool {
+	return slices.ContainsFunc(listValues(a), func(v interface{}) bool { return containsValue(b, v) })
+}
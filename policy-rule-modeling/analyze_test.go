@@ -0,0 +1,121 @@
+package policyrulemodeling
+
+import (
+	"testing"
+)
+
+func findingKinds(findings []Finding) map[string]FindingKind {
+	kinds := map[string]FindingKind{}
+	for _, f := range findings {
+		kinds[f.RuleID] = f.Kind
+	}
+	return kinds
+}
+
+func TestAnalyzePolicy(t *testing.T) {
+	roleIs := func(role string) Condition {
+		return Condition{Attribute: "subject.role", Operator: OpEquals, Value: role}
+	}
+
+	t.Run("reports rule shadowed by broader allow", func(t *testing.T) {
+		spec := PolicySpec{ID: "p", Rules: []*AttributeRule{
+			{ID: "staff-all", RuleEffect: EffectAllow, Conditions: []Condition{{Attribute: "subject.role", Operator: OpIn, Value: []string{"admin", "editor"}}}},
+			{ID: "editor-no-delete", RuleEffect: EffectDeny, Actions: []string{"delete"}, Conditions: []Condition{roleIs("editor")}},
+			{ID: "default-deny", RuleEffect: EffectDeny},
+		}}
+
+		findings := AnalyzePolicy(spec)
+
+		if got := findingKinds(findings)["editor-no-delete"]; got != FindingShadowed {
+			t.Errorf("Expected editor-no-delete to be shadowed, got %v", findings)
+		}
+		if findings[0].Severity != SeverityError {
+			t.Errorf("Expected shadowed deny to be an error, got %v", findings[0])
+		}
+	})
+
+	t.Run("reports contradictory conditions", func(t *testing.T) {
+		spec := PolicySpec{ID: "p", Rules: []*AttributeRule{
+			{ID: "impossible", RuleEffect: EffectAllow, Conditions: []Condition{roleIs("admin"), roleIs("editor")}},
+			{ID: "default-deny", RuleEffect: EffectDeny},
+		}}
+
+		if got := findingKinds(AnalyzePolicy(spec))["impossible"]; got != FindingUnreachable {
+			t.Errorf("Expected impossible to be unreachable, got %v", got)
+		}
+	})
+
+	t.Run("reports overlapping allow and deny", func(t *testing.T) {
+		spec := PolicySpec{ID: "p", Rules: []*AttributeRule{
+			{ID: "admin-all", RuleEffect: EffectAllow, Conditions: []Condition{roleIs("admin")}},
+			{ID: "no-delete", RuleEffect: EffectDeny, Actions: []string{"delete"}},
+			{ID: "default-deny", RuleEffect: EffectDeny},
+		}}
+
+		if got := findingKinds(AnalyzePolicy(spec))["no-delete"]; got != FindingConflict {
+			t.Errorf("Expected no-delete to conflict with admin-all, got %v", got)
+		}
+	})
+
+	t.Run("ignores disjoint allow and deny", func(t *testing.T) {
+		spec := PolicySpec{ID: "p", Rules: []*AttributeRule{
+			{ID: "admin-delete", RuleEffect: EffectAllow, Actions: []string{"delete"}, Conditions: []Condition{roleIs("admin")}},
+			{ID: "viewer-delete", RuleEffect: EffectDeny, Actions: []string{"delete"}, Conditions: []Condition{roleIs("viewer")}},
+			{ID: "default-deny", RuleEffect: EffectDeny},
+		}}
+
+		if findings := AnalyzePolicy(spec); len(findings) != 0 {
+			t.Errorf("Expected no findings, got %v", findings)
+		}
+	})
+
+	t.Run("reports missing default deny", func(t *testing.T) {
+		spec := PolicySpec{ID: "p", Rules: []*AttributeRule{
+			{ID: "admin-all", RuleEffect: EffectAllow, Conditions: []Condition{roleIs("admin")}},
+		}}
+
+		if got := findingKinds(AnalyzePolicy(spec))[""]; got != FindingNoDefaultDeny {
+			t.Errorf("Expected no-default-deny finding, got %v", got)
+		}
+	})
+
+	t.Run("reports catch-all deny in all-must-allow policy", func(t *testing.T) {
+		spec := PolicySpec{ID: "p", Combining: CombiningAllMustAllow, Rules: []*AttributeRule{
+			{ID: "admin-all", RuleEffect: EffectAllow, Conditions: []Condition{roleIs("admin")}},
+			{ID: "default-deny", RuleEffect: EffectDeny},
+		}}
+
+		if got := findingKinds(AnalyzePolicy(spec))["default-deny"]; got != FindingDenyAll {
+			t.Errorf("Expected deny-all finding, got %v", got)
+		}
+	})
+}
+
+func TestConditionsDisjoint(t *testing.T) {
+	eq := Condition{Attribute: "subject.role", Operator: OpEquals, Value: "admin"}
+	neq := Condition{Attribute: "subject.role", Operator: OpNotEquals, Value: "admin"}
+	in := Condition{Attribute: "subject.role", Operator: OpIn, Value: []string{"editor", "viewer"}}
+	exists := Condition{Attribute: "subject.role", Operator: OpExists}
+
+	tests := []struct {
+		name string
+		a, b Condition
+		want bool
+	}{
+		{"eq and neq of same value", eq, neq, true},
+		{"eq outside in", eq, in, true},
+		{"in without the neq value", in, neq, false},
+		{"eq and exists", eq, exists, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conditionsDisjoint(tt.a, tt.b); got != tt.want {
+				t.Errorf("Expected disjoint=%v, got %v", tt.want, got)
+			}
+			if got := conditionsDisjoint(tt.b, tt.a); got != tt.want {
+				t.Errorf("Expected disjoint=%v with operands swapped, got %v", tt.want, got)
+			}
+		})
+	}
+}
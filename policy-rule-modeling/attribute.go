@@ -0,0 +1,225 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+type Operator string
+
+const (
+	OpEquals    Operator = "eq"
+	OpNotEquals Operator = "neq"
+	OpIn        Operator = "in"
+	OpExists    Operator = "exists"
+)
+
+// Condition compares the attribute at path Attribute with Value, or with the
+// attribute at path ValueFrom. Paths are "subject.id", "subject.<attr>",
+// "resource.type", "resource.id", "resource.<attr>" and "action".
+type Condition struct {
+	Attribute string      `json:"attribute"`
+	Operator  Operator    `json:"op"`
+	Value     interface{} `json:"value,omitempty"`
+	ValueFrom string      `json:"value_from,omitempty"`
+}
+
+func (c Condition) Validate() error {
+	if err := validatePath(c.Attribute); err != nil {
+		return err
+	}
+	if c.ValueFrom != "" {
+		if err := validatePath(c.ValueFrom); err != nil {
+			return err
+		}
+	}
+
+	switch c.Operator {
+	case OpEquals, OpNotEquals, OpExists:
+	case OpIn:
+		if reflect.ValueOf(c.Value).Kind() != reflect.Slice && c.ValueFrom == "" {
+			return fmt.Errorf("condition on %s: %q needs a list value", c.Attribute, c.Operator)
+		}
+	default:
+		return fmt.Errorf("condition on %s: unknown operator %q", c.Attribute, c.Operator)
+	}
+	return nil
+}
+
+func validatePath(path string) error {
+	if path == "action" {
+		return nil
+	}
+	scope, name, ok := strings.Cut(path, ".")
+	if !ok || name == "" || (scope != "subject" && scope != "resource") {
+		return fmt.Errorf("invalid attribute path %q", path)
+	}
+	return nil
+}
+
+func (c Condition) Matches(subject Subject, resource Resource, action Action) bool {
+	got, ok := resolveAttribute(c.Attribute, subject, resource, action)
+	if c.Operator == OpExists {
+		return ok
+	}
+
+	want := c.Value
+	if c.ValueFrom != "" {
+		var found bool
+		if want, found = resolveAttribute(c.ValueFrom, subject, resource, action); !found {
+			return false
+		}
+	}
+
+	switch c.Operator {
+	case OpEquals:
+		return ok && equalValues(got, want)
+	case OpNotEquals:
+		return !ok || !equalValues(got, want)
+	case OpIn:
+		return ok && containsValue(want, got)
+	default:
+		return false
+	}
+}
+
+func (c Condition) String() string {
+	switch {
+	case c.Operator == OpExists:
+		return fmt.Sprintf("%s exists", c.Attribute)
+	case c.ValueFrom != "":
+		return fmt.Sprintf("%s %s %s", c.Attribute, c.Operator, c.ValueFrom)
+	default:
+		return fmt.Sprintf("%s %s %v", c.Attribute, c.Operator, c.Value)
+	}
+}
+
+func resolveAttribute(path string, subject Subject, resource Resource, action Action) (interface{}, bool) {
+	if path == "action" {
+		return action.GetName(), true
+	}
+
+	scope, name, _ := strings.Cut(path, ".")
+	switch {
+	case scope == "subject" && name == "id":
+		return subject.GetID(), true
+	case scope == "subject":
+		v, ok := subject.GetAttributes()[name]
+		return v, ok
+	case scope == "resource" && name == "id":
+		return resource.GetID(), true
+	case scope == "resource" && name == "type":
+		return resource.GetType(), true
+	case scope == "resource":
+		v, ok := resource.GetAttributes()[name]
+		return v, ok
+	default:
+		return nil, false
+	}
+}
+
+// equalValues treats all numeric kinds alike, since attributes decoded from
+// JSON are float64 while attributes set in Go are usually int.
+func equalValues(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func containsValue(list, v interface{}) bool {
+	return slices.ContainsFunc(listValues(list), func(item interface{}) bool {
+		return equalValues(item, v)
+	})
+}
+
+// listValues accepts []interface{} from JSON as well as typed slices from Go.
+func listValues(list interface{}) []interface{} {
+	rv := reflect.ValueOf(list)
+	if rv.Kind() != reflect.Slice {
+		return nil
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// AttributeRule is a declarative rule: it matches when the action and
+// resource type are listed (empty lists match anything) and all conditions hold.
+type AttributeRule struct {
+	ID            string       `json:"id"`
+	RuleEffect    Effect       `json:"effect"`
+	RulePriority  int          `json:"priority,omitempty"`
+	Actions       []string     `json:"actions,omitempty"`
+	ResourceTypes []string     `json:"resource_types,omitempty"`
+	Conditions    []Condition  `json:"conditions,omitempty"`
+	Obligations   []Obligation `json:"obligations,omitempty"`
+	Advice        []Obligation `json:"advice,omitempty"`
+}
+
+func (r *AttributeRule) Matches(_ context.Context, subject Subject, resource Resource, action Action) bool {
+	if len(r.Actions) > 0 && !slices.Contains(r.Actions, action.GetName()) {
+		return false
+	}
+	if len(r.ResourceTypes) > 0 && !slices.Contains(r.ResourceTypes, resource.GetType()) {
+		return false
+	}
+	for _, c := range r.Conditions {
+		if !c.Matches(subject, resource, action) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *AttributeRule) GetID() string {
+	return r.ID
+}
+
+func (r *AttributeRule) Effect() Effect {
+	return r.RuleEffect
+}
+
+func (r *AttributeRule) Priority() int {
+	return r.RulePriority
+}
+
+func (r *AttributeRule) GetObligations() []Obligation {
+	return r.Obligations
+}
+
+func (r *AttributeRule) GetAdvice() []Obligation {
+	return r.Advice
+}
+
+func (r *AttributeRule) Validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("rule without id")
+	}
+	for _, c := range r.Conditions {
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+	}
+	return nil
+}
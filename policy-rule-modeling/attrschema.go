@@ -0,0 +1,231 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrUndeclaredAttribute = errors.New("undeclared attribute")
+	ErrMissingAttribute    = errors.New("missing required attribute")
+	ErrAttributeType       = errors.New("attribute has wrong type")
+)
+
+type AttributeType string
+
+const (
+	TypeString     AttributeType = "string"
+	TypeInt        AttributeType = "int"
+	TypeFloat      AttributeType = "float"
+	TypeBool       AttributeType = "bool"
+	TypeStringList AttributeType = "string_list"
+)
+
+type AttributeDef struct {
+	Name     string        `json:"name"`
+	Type     AttributeType `json:"type"`
+	Required bool          `json:"required,omitempty"`
+}
+
+func (d AttributeDef) check(v interface{}) bool {
+	switch d.Type {
+	case TypeString:
+		_, ok := v.(string)
+		return ok
+	case TypeInt:
+		f, ok := toFloat(v)
+		return ok && f == math.Trunc(f)
+	case TypeFloat:
+		_, ok := toFloat(v)
+		return ok
+	case TypeBool:
+		_, ok := v.(bool)
+		return ok
+	case TypeStringList:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return false
+		}
+		for _, item := range listValues(v) {
+			if _, ok := item.(string); !ok {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// AttributeSchema declares the attributes subjects carry and, per resource
+// type, the attributes resources carry.
+type AttributeSchema struct {
+	Subject   []AttributeDef            `json:"subject"`
+	Resources map[string][]AttributeDef `json:"resources"`
+}
+
+func (s *AttributeSchema) lookup(defs []AttributeDef, name string) (AttributeDef, bool) {
+	for _, d := range defs {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return AttributeDef{}, false
+}
+
+// Validate checks the subject and resource attributes against the schema and
+// returns every problem found, joined.
+func (s *AttributeSchema) Validate(subject Subject, resource Resource) error {
+	var errs []error
+	errs = append(errs, validateAttributes("subject", s.Subject, subject.GetAttributes())...)
+
+	defs, ok := s.Resources[resource.GetType()]
+	if !ok {
+		errs = append(errs, fmt.Errorf("resource type %q: %w", resource.GetType(), ErrUndeclaredAttribute))
+	} else {
+		errs = append(errs, validateAttributes("resource."+resource.GetType(), defs, resource.GetAttributes())...)
+	}
+	return errors.Join(errs...)
+}
+
+func validateAttributes(scope string, defs []AttributeDef, attrs map[string]interface{}) []error {
+	var errs []error
+	declared := map[string]bool{}
+	for _, d := range defs {
+		declared[d.Name] = true
+		v, ok := attrs[d.Name]
+		switch {
+		case !ok && d.Required:
+			errs = append(errs, fmt.Errorf("%s.%s: %w", scope, d.Name, ErrMissingAttribute))
+		case ok && !d.check(v):
+			errs = append(errs, fmt.Errorf("%s.%s: %w: want %s, got %T", scope, d.Name, ErrAttributeType, d.Type, v))
+		}
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !declared[name] {
+			errs = append(errs, fmt.Errorf("%s.%s: %w", scope, name, ErrUndeclaredAttribute))
+		}
+	}
+	return errs
+}
+
+// CheckPolicy reports conditions of spec that reference attributes the
+// schema does not declare, such as a misspelled "subject.rol".
+func (s *AttributeSchema) CheckPolicy(spec PolicySpec) error {
+	var errs []error
+	for _, rule := range spec.Rules {
+		for _, c := range rule.Conditions {
+			for _, path := range []string{c.Attribute, c.ValueFrom} {
+				if err := s.checkPath(path, rule.ResourceTypes); err != nil {
+					errs = append(errs, fmt.Errorf("policy %s rule %s: %w", spec.ID, rule.ID, err))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *AttributeSchema) checkPath(path string, resourceTypes []string) error {
+	scope, name, _ := strings.Cut(path, ".")
+	switch {
+	case path == "" || path == "action":
+		return nil
+	case scope == "subject" && name != "id":
+		if _, ok := s.lookup(s.Subject, name); !ok {
+			return fmt.Errorf("%s: %w", path, ErrUndeclaredAttribute)
+		}
+	case scope == "resource" && name != "id" && name != "type":
+		types := resourceTypes
+		if len(types) == 0 {
+			for typ := range s.Resources {
+				types = append(types, typ)
+			}
+			sort.Strings(types)
+		}
+		for _, typ := range types {
+			if _, ok := s.lookup(s.Resources[typ], name); !ok {
+				return fmt.Errorf("%s on %s: %w", path, typ, ErrUndeclaredAttribute)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidatingPolicy denies requests whose attributes violate the schema
+// instead of letting mistyped attributes silently fail to match.
+type ValidatingPolicy struct {
+	next   Policy
+	schema *AttributeSchema
+}
+
+func NewValidatingPolicy(next Policy, schema *AttributeSchema) *ValidatingPolicy {
+	return &ValidatingPolicy{next: next, schema: schema}
+}
+
+func (p *ValidatingPolicy) Evaluate(ctx context.Context, subject Subject, resource Resource, action Action) Decision {
+	if err := p.schema.Validate(subject, resource); err != nil {
+		return Decision{Allow: false, Reason: "invalid attributes: " + strings.ReplaceAll(err.Error(), "\n", "; ")}
+	}
+	return p.next.Evaluate(ctx, subject, resource, action)
+}
+
+func (p *ValidatingPolicy) GetID() string {
+	return p.next.GetID()
+}
+
+func (p *ValidatingPolicy) GetName() string {
+	return p.next.GetName()
+}
+
+// Attr returns attrs[name] as T. Numeric attributes convert between int and
+// float64, since JSON decoding yields float64 for every number.
+func Attr[T any](attrs map[string]interface{}, name string) (T, bool) {
+	var zero T
+	v, ok := attrs[name]
+	if !ok {
+		return zero, false
+	}
+	if t, ok := v.(T); ok {
+		return t, true
+	}
+
+	f, ok := toFloat(v)
+	if !ok {
+		return zero, false
+	}
+	switch any(zero).(type) {
+	case int:
+		if f != math.Trunc(f) {
+			return zero, false
+		}
+		return any(int(f)).(T), true
+	case int64:
+		if f != math.Trunc(f) {
+			return zero, false
+		}
+		return any(int64(f)).(T), true
+	case float64:
+		return any(f).(T), true
+	default:
+		return zero, false
+	}
+}
+
+func SubjectAttr[T any](subject Subject, name string) (T, bool) {
+	return Attr[T](subject.GetAttributes(), name)
+}
+
+func ResourceAttr[T any](resource Resource, name string) (T, bool) {
+	return Attr[T](resource.GetAttributes(), name)
+}
@@ -0,0 +1,92 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+var documentAttributes = &AttributeSchema{
+	Subject: []AttributeDef{
+		{Name: "role", Type: TypeString, Required: true},
+		{Name: "clearance", Type: TypeInt},
+	},
+	Resources: map[string][]AttributeDef{
+		"document": {
+			{Name: "owner", Type: TypeString, Required: true},
+			{Name: "classification", Type: TypeString},
+			{Name: "tags", Type: TypeStringList},
+		},
+	},
+}
+
+func TestAttributeSchema_Validate(t *testing.T) {
+	doc := SimpleResource{Type: "document", ID: "doc1", Attributes: map[string]interface{}{"owner": "user123", "tags": []interface{}{"hr"}}}
+
+	tests := []struct {
+		name    string
+		subject SimpleSubject
+		want    error
+	}{
+		{"accepts valid attributes", SimpleSubject{ID: "u", Attributes: map[string]interface{}{"role": "admin", "clearance": 3.0}}, nil},
+		{"rejects misspelled attribute", SimpleSubject{ID: "u", Attributes: map[string]interface{}{"rol": "admin"}}, ErrUndeclaredAttribute},
+		{"rejects missing required attribute", SimpleSubject{ID: "u", Attributes: map[string]interface{}{}}, ErrMissingAttribute},
+		{"rejects wrong type", SimpleSubject{ID: "u", Attributes: map[string]interface{}{"role": "admin", "clearance": "high"}}, ErrAttributeType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := documentAttributes.Validate(tt.subject, doc)
+			if tt.want == nil && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestAttributeSchema_CheckPolicy(t *testing.T) {
+	spec := PolicySpec{ID: "documents", Rules: []*AttributeRule{
+		{ID: "admin-all", RuleEffect: EffectAllow, Conditions: []Condition{{Attribute: "subject.rol", Operator: OpEquals, Value: "admin"}}},
+		{ID: "owner-all", RuleEffect: EffectAllow, ResourceTypes: []string{"document"}, Conditions: []Condition{{Attribute: "resource.owner", Operator: OpEquals, ValueFrom: "subject.id"}}},
+	}}
+
+	err := documentAttributes.CheckPolicy(spec)
+	if !errors.Is(err, ErrUndeclaredAttribute) {
+		t.Fatalf("Expected ErrUndeclaredAttribute, got %v", err)
+	}
+	if got := err.Error(); got != "policy documents rule admin-all: subject.rol: undeclared attribute" {
+		t.Errorf("Expected error naming the rule and attribute, got %q", got)
+	}
+}
+
+func TestValidatingPolicy(t *testing.T) {
+	allowAll := &SimplePolicy{ID: "p", Name: "Allow All", Rules: []Rule{&AttributeRule{ID: "all", RuleEffect: EffectAllow}}}
+	policy := NewValidatingPolicy(allowAll, documentAttributes)
+	doc := SimpleResource{Type: "document", ID: "doc1", Attributes: map[string]interface{}{"owner": "user123"}}
+
+	decision := policy.Evaluate(context.Background(), SimpleSubject{ID: "u", Attributes: map[string]interface{}{"rol": "admin"}}, doc, SimpleAction{Name: "read"})
+
+	if decision.Allow {
+		t.Errorf("Expected invalid attributes to be denied, got %+v", decision)
+	}
+}
+
+func TestAttr(t *testing.T) {
+	attrs := map[string]interface{}{"role": "admin", "clearance": 3.0, "level": 2}
+
+	if role, ok := Attr[string](attrs, "role"); !ok || role != "admin" {
+		t.Errorf("Expected role admin, got %q", role)
+	}
+	if clearance, ok := Attr[int](attrs, "clearance"); !ok || clearance != 3 {
+		t.Errorf("Expected clearance 3 from JSON number, got %d", clearance)
+	}
+	if level, ok := Attr[float64](attrs, "level"); !ok || level != 2 {
+		t.Errorf("Expected level 2.0 from int, got %v", level)
+	}
+	if _, ok := Attr[bool](attrs, "role"); ok {
+		t.Errorf("Expected type mismatch to report false")
+	}
+}
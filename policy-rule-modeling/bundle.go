@@ -0,0 +1,105 @@
+package policyrulemodeling
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	CombiningFirstMatch   = "first-match"
+	CombiningAllMustAllow = "all-must-allow"
+)
+
+// PolicySpec is the on-disk form of a policy made of AttributeRules.
+// Combining selects SimplePolicy ("first-match", the default) or
+// AllMustAllowPolicy ("all-must-allow").
+type PolicySpec struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	Combining string           `json:"combining,omitempty"`
+	Rules     []*AttributeRule `json:"rules"`
+}
+
+func (s PolicySpec) Validate() error {
+	if s.ID == "" {
+		return fmt.Errorf("policy without id")
+	}
+	switch s.Combining {
+	case "", CombiningFirstMatch, CombiningAllMustAllow:
+	default:
+		return fmt.Errorf("policy %s: unknown combining algorithm %q", s.ID, s.Combining)
+	}
+
+	seen := map[string]bool{}
+	for i, r := range s.Rules {
+		if r == nil {
+			return fmt.Errorf("policy %s: rule %d is null", s.ID, i)
+		}
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("policy %s: %w", s.ID, err)
+		}
+		if seen[r.ID] {
+			return fmt.Errorf("policy %s: duplicate rule id %s", s.ID, r.ID)
+		}
+		seen[r.ID] = true
+	}
+	return nil
+}
+
+func (s PolicySpec) Build() Policy {
+	rules := make([]Rule, len(s.Rules))
+	for i, r := range s.Rules {
+		rules[i] = r
+	}
+
+	if s.Combining == CombiningAllMustAllow {
+		return &AllMustAllowPolicy{ID: s.ID, Name: s.Name, Rules: rules}
+	}
+	return &SimplePolicy{ID: s.ID, Name: s.Name, Rules: rules}
+}
+
+// Bundle is a set of policies distributed and loaded together.
+type Bundle struct {
+	Revision string       `json:"revision,omitempty"`
+	Policies []PolicySpec `json:"policies"`
+}
+
+func LoadBundle(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle %s: %w", path, err)
+	}
+
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle %s: %w", path, err)
+	}
+	if err := b.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid bundle %s: %w", path, err)
+	}
+	return &b, nil
+}
+
+func (b *Bundle) Validate() error {
+	seen := map[string]bool{}
+	for _, s := range b.Policies {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+		if seen[s.ID] {
+			return fmt.Errorf("duplicate policy id %s", s.ID)
+		}
+		seen[s.ID] = true
+	}
+	return nil
+}
+
+// Build returns the bundle's policies keyed by ID.
+func (b *Bundle) Build() map[string]Policy {
+	policies := make(map[string]Policy, len(b.Policies))
+	for _, s := range b.Policies {
+		policies[s.ID] = s.Build()
+	}
+	return policies
+}
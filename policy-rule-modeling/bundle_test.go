@@ -0,0 +1,71 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestLoadBundle(t *testing.T) {
+	ctx := context.Background()
+	bundle, err := LoadBundle("testdata/bundle.json")
+	if err != nil {
+		t.Fatalf("Expected bundle to load, got %v", err)
+	}
+
+	policy, ok := bundle.Build()["documents"]
+	if !ok {
+		t.Fatalf("Expected policy 'documents' in bundle")
+	}
+
+	admin := SimpleSubject{ID: "admin123", Attributes: map[string]interface{}{"role": "admin"}}
+	viewer := SimpleSubject{ID: "user456", Attributes: map[string]interface{}{"role": "viewer"}}
+	owned := SimpleResource{Type: "document", ID: "doc1", Attributes: map[string]interface{}{"owner": "user456"}}
+	secret := SimpleResource{Type: "document", ID: "doc2", Attributes: map[string]interface{}{"owner": "user456", "classification": "confidential"}}
+	other := SimpleResource{Type: "document", ID: "doc3", Attributes: map[string]interface{}{"owner": "admin123"}}
+
+	tests := []struct {
+		name     string
+		subject  Subject
+		resource Resource
+		action   string
+		allow    bool
+	}{
+		{"admin reads confidential", admin, secret, "read", true},
+		{"owner deletes own document", viewer, owned, "delete", true},
+		{"owner denied confidential", viewer, secret, "read", false},
+		{"viewer reads other document", viewer, other, "read", true},
+		{"viewer cannot delete other document", viewer, other, "delete", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := policy.Evaluate(ctx, tt.subject, tt.resource, SimpleAction{Name: tt.action})
+			if decision.Allow != tt.allow {
+				t.Errorf("Expected Allow=%v, got %+v", tt.allow, decision)
+			}
+		})
+	}
+}
+
+func TestBundleValidate(t *testing.T) {
+	bundle := &Bundle{Policies: []PolicySpec{{
+		ID: "broken",
+		Rules: []*AttributeRule{{
+			ID:         "bad-op",
+			Conditions: []Condition{{Attribute: "subject.role", Operator: "like", Value: "adm"}},
+		}},
+	}}}
+
+	if err := bundle.Validate(); err == nil {
+		t.Errorf("Expected validation error for unknown operator")
+	}
+
+	var spec PolicySpec
+	if err := json.Unmarshal([]byte(`{"id":"nulls","rules":[null]}`), &spec); err != nil {
+		t.Fatalf("Expected spec to decode, got %v", err)
+	}
+	if err := spec.Validate(); err == nil {
+		t.Errorf("Expected validation error for null rule")
+	}
+}
@@ -0,0 +1,243 @@
+package policyrulemodeling
+
+import (
+	"container/list"
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+// CacheFieldKeys are the label names of the counters set by
+// CachingPolicy.SetMetrics.
+var CacheFieldKeys = []string{"policy_id"}
+
+type cacheKey struct {
+	subjectID    string
+	resourceType string
+	resourceID   string
+	action       string
+	version      uint64
+
+	// the Environment that rules such as TenantIsolationRule, IPRule and
+	// BusinessHoursRule decide on
+	tenant   string
+	clientIP netip.Addr
+	minute   time.Time
+}
+
+// envTimeBucket is how precisely cached decisions follow time-window rules.
+const envTimeBucket = time.Minute
+
+type cacheEntry struct {
+	key       cacheKey
+	decision  Decision
+	expiresAt time.Time
+}
+
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+// HitRatio returns hits / (hits + misses), or 0 when nothing was looked up yet.
+func (s CacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// CachingPolicy memoizes decisions of the wrapped policy. Entries are keyed on
+// the subject, resource, action, the current policy version and the request
+// Environment's tenant, client IP and minute, expire after TTL and are evicted
+// least-recently-used once MaxEntries is reached.
+type CachingPolicy struct {
+	next       Policy
+	ttl        time.Duration
+	maxEntries int
+	clock      Clock
+
+	mu      sync.Mutex
+	version uint64
+	// generation counts purges, so a miss evaluated across one isn't stored
+	generation uint64
+	entries    map[cacheKey]*list.Element
+	lru        *list.List
+	stats      CacheStats
+
+	hits, misses, evictions metrics.Counter
+}
+
+func NewCachingPolicy(next Policy, ttl time.Duration, maxEntries int, clock Clock) *CachingPolicy {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	return &CachingPolicy{
+		next:       next,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		clock:      clock,
+		entries:    map[cacheKey]*list.Element{},
+		lru:        list.New(),
+		hits:       discard.NewCounter(),
+		misses:     discard.NewCounter(),
+		evictions:  discard.NewCounter(),
+	}
+}
+
+// SetMetrics exports hits, misses and evictions, labelled with CacheFieldKeys,
+// in addition to Stats. Call it before the policy is used.
+func (p *CachingPolicy) SetMetrics(hits, misses, evictions metrics.Counter) {
+	lvs := []string{"policy_id", p.next.GetID()}
+	p.hits = hits.With(lvs...)
+	p.misses = misses.With(lvs...)
+	p.evictions = evictions.With(lvs...)
+}
+
+func (p *CachingPolicy) Evaluate(ctx context.Context, subject Subject, resource Resource, action Action) Decision {
+	env := EnvironmentFromContext(ctx)
+	if env.Clock == nil {
+		env.Clock = p.clock
+	}
+	p.mu.Lock()
+	key := cacheKey{
+		subjectID:    subject.GetID(),
+		resourceType: resource.GetType(),
+		resourceID:   resource.GetID(),
+		action:       action.GetName(),
+		version:      p.version,
+		tenant:       env.Tenant,
+		clientIP:     env.ClientIP,
+		minute:       env.Now().Truncate(envTimeBucket),
+	}
+	if decision, ok := p.lookup(key); ok {
+		p.stats.Hits++
+		p.hits.Add(1)
+		p.mu.Unlock()
+		return decision
+	}
+	p.stats.Misses++
+	p.misses.Add(1)
+	generation := p.generation
+	p.mu.Unlock()
+
+	decision := p.next.Evaluate(ctx, subject, resource, action)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// a purge while evaluating means the decision may be stale already
+	if generation == p.generation {
+		p.store(key, decision)
+	}
+	return decision
+}
+
+func (p *CachingPolicy) GetID() string {
+	return p.next.GetID()
+}
+
+func (p *CachingPolicy) GetName() string {
+	return p.next.GetName()
+}
+
+// lookup must be called with mu held.
+func (p *CachingPolicy) lookup(key cacheKey) (Decision, bool) {
+	elem, ok := p.entries[key]
+	if !ok {
+		return Decision{}, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if p.ttl > 0 && !p.clock.Now().Before(entry.expiresAt) {
+		p.remove(elem)
+		return Decision{}, false
+	}
+
+	p.lru.MoveToFront(elem)
+	return entry.decision, true
+}
+
+// store must be called with mu held.
+func (p *CachingPolicy) store(key cacheKey, decision Decision) {
+	entry := &cacheEntry{key: key, decision: decision, expiresAt: p.clock.Now().Add(p.ttl)}
+	if elem, ok := p.entries[key]; ok {
+		elem.Value = entry
+		p.lru.MoveToFront(elem)
+		return
+	}
+
+	p.entries[key] = p.lru.PushFront(entry)
+	for p.maxEntries > 0 && p.lru.Len() > p.maxEntries {
+		p.remove(p.lru.Back())
+		p.stats.Evictions++
+		p.evictions.Add(1)
+	}
+}
+
+func (p *CachingPolicy) remove(elem *list.Element) {
+	entry := p.lru.Remove(elem).(*cacheEntry)
+	delete(p.entries, entry.key)
+}
+
+// SetVersion switches the cache to a new policy version. Entries cached under
+// the previous version are dropped.
+func (p *CachingPolicy) SetVersion(version uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if version == p.version {
+		return
+	}
+	p.version = version
+	p.purge(func(cacheKey) bool { return true })
+}
+
+func (p *CachingPolicy) Version() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.version
+}
+
+// Invalidate drops every cached decision, e.g. after the wrapped policy changed.
+func (p *CachingPolicy) Invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.purge(func(cacheKey) bool { return true })
+}
+
+// InvalidateSubject drops decisions for a subject whose attributes changed.
+func (p *CachingPolicy) InvalidateSubject(subjectID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.purge(func(k cacheKey) bool { return k.subjectID == subjectID })
+}
+
+// InvalidateResource drops decisions for a resource whose attributes changed.
+func (p *CachingPolicy) InvalidateResource(resourceType, resourceID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.purge(func(k cacheKey) bool { return k.resourceType == resourceType && k.resourceID == resourceID })
+}
+
+func (p *CachingPolicy) purge(match func(cacheKey) bool) {
+	p.generation++
+	for key, elem := range p.entries {
+		if match(key) {
+			p.remove(elem)
+		}
+	}
+}
+
+func (p *CachingPolicy) Stats() CacheStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.Size = p.lru.Len()
+	return stats
+}
@@ -0,0 +1,185 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+type countingPolicy struct {
+	SimplePolicy
+	calls int
+}
+
+func (p *countingPolicy) Evaluate(ctx context.Context, subject Subject, resource Resource, action Action) Decision {
+	p.calls++
+	return p.SimplePolicy.Evaluate(ctx, subject, resource, action)
+}
+
+func newCountingPolicy() *countingPolicy {
+	return &countingPolicy{SimplePolicy: SimplePolicy{
+		ID:   "counting-policy",
+		Name: "Counting Policy",
+		Rules: []Rule{&MockRule{
+			ID:         "allow-read",
+			RuleEffect: EffectAllow,
+			MatchFunc: func(ctx context.Context, subject Subject, resource Resource, action Action) bool {
+				return action.GetName() == "read"
+			},
+		}},
+	}}
+}
+
+func TestCachingPolicy_Evaluate(t *testing.T) {
+	ctx := context.Background()
+	subject := &MockSubject{ID: "user123"}
+	doc1 := &MockResource{Type: "document", ID: "doc1"}
+	doc2 := &MockResource{Type: "document", ID: "doc2"}
+	doc3 := &MockResource{Type: "document", ID: "doc3"}
+	read := &MockAction{Name: "read"}
+
+	t.Run("serves repeated lookups from cache", func(t *testing.T) {
+		next := newCountingPolicy()
+		policy := NewCachingPolicy(next, time.Minute, 10, &fakeClock{now: time.Now()})
+
+		first := policy.Evaluate(ctx, subject, doc1, read)
+		second := policy.Evaluate(ctx, subject, doc1, read)
+
+		if next.calls != 1 {
+			t.Errorf("Expected 1 call to wrapped policy, got %d", next.calls)
+		}
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("Expected cached decision %v, got %v", first, second)
+		}
+		if ratio := policy.Stats().HitRatio(); ratio != 0.5 {
+			t.Errorf("Expected hit ratio 0.5, got %v", ratio)
+		}
+	})
+
+	t.Run("expires entries after ttl", func(t *testing.T) {
+		next := newCountingPolicy()
+		clock := &fakeClock{now: time.Now()}
+		policy := NewCachingPolicy(next, time.Minute, 10, clock)
+
+		policy.Evaluate(ctx, subject, doc1, read)
+		clock.now = clock.now.Add(time.Minute)
+		policy.Evaluate(ctx, subject, doc1, read)
+
+		if next.calls != 2 {
+			t.Errorf("Expected 2 calls to wrapped policy, got %d", next.calls)
+		}
+	})
+
+	t.Run("evicts least recently used entry", func(t *testing.T) {
+		next := newCountingPolicy()
+		policy := NewCachingPolicy(next, time.Minute, 2, &fakeClock{now: time.Now()})
+
+		policy.Evaluate(ctx, subject, doc1, read)
+		policy.Evaluate(ctx, subject, doc2, read)
+		policy.Evaluate(ctx, subject, doc1, read) // doc1 becomes most recently used
+		policy.Evaluate(ctx, subject, doc3, read) // evicts doc2
+		policy.Evaluate(ctx, subject, doc1, read)
+		policy.Evaluate(ctx, subject, doc2, read)
+
+		if next.calls != 4 {
+			t.Errorf("Expected 4 calls to wrapped policy, got %d", next.calls)
+		}
+		if stats := policy.Stats(); stats.Evictions != 2 || stats.Size != 2 {
+			t.Errorf("Expected 2 evictions and size 2, got %+v", stats)
+		}
+	})
+
+	t.Run("exports counters", func(t *testing.T) {
+		recorded := &recordedMetrics{values: map[string]float64{}}
+		policy := NewCachingPolicy(newCountingPolicy(), time.Minute, 1, &fakeClock{now: time.Now()})
+		policy.SetMetrics(
+			fakeMetric{recorded, []string{"hits"}},
+			fakeMetric{recorded, []string{"misses"}},
+			fakeMetric{recorded, []string{"evictions"}},
+		)
+
+		policy.Evaluate(ctx, subject, doc1, read)
+		policy.Evaluate(ctx, subject, doc1, read)
+		policy.Evaluate(ctx, subject, doc2, read) // evicts doc1
+
+		want := map[string]float64{
+			"hits,policy_id,counting-policy":      1,
+			"misses,policy_id,counting-policy":    2,
+			"evictions,policy_id,counting-policy": 1,
+		}
+		if !reflect.DeepEqual(recorded.values, want) {
+			t.Errorf("Expected %v, got %v", want, recorded.values)
+		}
+	})
+
+	t.Run("invalidates by subject, resource and version", func(t *testing.T) {
+		next := newCountingPolicy()
+		policy := NewCachingPolicy(next, time.Minute, 10, &fakeClock{now: time.Now()})
+
+		policy.Evaluate(ctx, subject, doc1, read)
+		policy.InvalidateSubject("user123")
+		policy.Evaluate(ctx, subject, doc1, read)
+		policy.InvalidateResource("document", "doc1")
+		policy.Evaluate(ctx, subject, doc1, read)
+		policy.SetVersion(2)
+		policy.Evaluate(ctx, subject, doc1, read)
+		policy.Evaluate(ctx, subject, doc1, read)
+
+		if next.calls != 4 {
+			t.Errorf("Expected 4 calls to wrapped policy, got %d", next.calls)
+		}
+	})
+
+	t.Run("does not store decisions invalidated while evaluating", func(t *testing.T) {
+		next := newCountingPolicy()
+		policy := NewCachingPolicy(next, time.Minute, 10, &fakeClock{now: time.Now()})
+		next.SimplePolicy.Rules = append([]Rule{&MockRule{
+			ID:         "invalidate-midway",
+			RuleEffect: EffectDeny,
+			MatchFunc: func(ctx context.Context, subject Subject, resource Resource, action Action) bool {
+				if next.calls == 1 {
+					policy.InvalidateResource("document", "doc1")
+				}
+				return false
+			},
+		}}, next.SimplePolicy.Rules...)
+
+		policy.Evaluate(ctx, subject, doc1, read)
+		policy.Evaluate(ctx, subject, doc1, read)
+
+		if next.calls != 2 {
+			t.Errorf("Expected 2 calls to wrapped policy, got %d", next.calls)
+		}
+	})
+
+	t.Run("keys on the request environment", func(t *testing.T) {
+		next := newCountingPolicy()
+		policy := NewCachingPolicy(next, time.Hour, 10, &fakeClock{now: time.Now()})
+		noon := time.Date(2025, time.June, 30, 12, 0, 0, 0, time.UTC)
+		envs := []Environment{
+			{Clock: &fakeClock{now: noon}, ClientIP: netip.MustParseAddr("10.1.2.3"), Tenant: "acme"},
+			{Clock: &fakeClock{now: noon}, ClientIP: netip.MustParseAddr("10.1.2.3"), Tenant: "globex"},
+			{Clock: &fakeClock{now: noon}, ClientIP: netip.MustParseAddr("203.0.113.7"), Tenant: "acme"},
+			{Clock: &fakeClock{now: noon.Add(5 * time.Hour)}, ClientIP: netip.MustParseAddr("10.1.2.3"), Tenant: "acme"},
+		}
+
+		for _, env := range envs {
+			policy.Evaluate(ContextWithEnvironment(ctx, env), subject, doc1, read)
+		}
+		policy.Evaluate(ContextWithEnvironment(ctx, envs[0]), subject, doc1, read)
+
+		if next.calls != len(envs) {
+			t.Errorf("Expected %d calls to wrapped policy, got %d", len(envs), next.calls)
+		}
+	})
+}
@@ -0,0 +1,13 @@
+package policyrulemodeling
+
+import "time"
+
+type Clock interface {
+	Now() time.Time
+}
+
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+	"github.com/shiiyan/learn-go/policy-rule-modeling/pdp"
+)
+
+func main() {
+	var (
+		listen      = flag.String("listen", ":8181", "HTTP listen address")
+		adminListen = flag.String("admin-listen", "localhost:8182", "HTTP listen address for managing tenant policies; keep it internal")
+		bundlePath  = flag.String("bundle", "bundle.json", "Policy bundle to serve, reloaded on SIGHUP")
+		sampleEvery = flag.Uint64("log-allow-every", 100, "Log one in N allow decisions; denies are always logged")
+		cacheTTL    = flag.Duration("cache-ttl", 0, "Cache decisions for this long; 0 disables the cache")
+		cacheSize   = flag.Int("cache-size", 10000, "Maximum cached decisions per policy")
+	)
+	flag.Parse()
+
+	var logger log.Logger
+	logger = log.NewLogfmtLogger(os.Stderr)
+	logger = log.With(logger, "listen", *listen, "caller", log.DefaultCaller)
+
+	decisionCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "my_group",
+		Subsystem: "policy",
+		Name:      "decision_count",
+		Help:      "Number of policy decisions.",
+	}, prm.DecisionFieldKeys)
+	decisionLatency := kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: "my_group",
+		Subsystem: "policy",
+		Name:      "decision_latency_seconds",
+		Help:      "Duration of policy evaluation in seconds.",
+		Buckets:   stdprometheus.ExponentialBuckets(0.00001, 4, 8),
+	}, prm.DecisionFieldKeys)
+
+	decorators := []func(prm.Policy) prm.Policy{
+		func(p prm.Policy) prm.Policy { return prm.NewLoggingPolicy(p, logger, *sampleEvery) },
+		func(p prm.Policy) prm.Policy { return prm.NewInstrumentingPolicy(p, decisionCount, decisionLatency) },
+	}
+	if *cacheTTL > 0 {
+		cacheCounter := func(name, help string) *kitprometheus.Counter {
+			return kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "my_group",
+				Subsystem: "policy",
+				Name:      name,
+				Help:      help,
+			}, prm.CacheFieldKeys)
+		}
+		hits := cacheCounter("cache_hits", "Number of decisions served from the cache.")
+		misses := cacheCounter("cache_misses", "Number of decisions evaluated on a cache miss.")
+		evictions := cacheCounter("cache_evictions", "Number of cached decisions evicted to stay within -cache-size.")
+		// innermost, so decisions served from the cache are logged and
+		// counted like any other
+		cache := func(p prm.Policy) prm.Policy {
+			c := prm.NewCachingPolicy(p, *cacheTTL, *cacheSize, prm.RealClock{})
+			c.SetMetrics(hits, misses, evictions)
+			return c
+		}
+		decorators = append([]func(prm.Policy) prm.Policy{cache}, decorators...)
+	}
+	reg := pdp.NewRegistry(decorators...)
+	tenants := prm.NewTenantRegistry(decorators...)
+	if err := load(reg, *bundlePath); err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	logger.Log("bundle", *bundlePath, "revision", reg.Revision(), "policies", len(reg.IDs()))
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := load(reg, *bundlePath); err != nil {
+				// keep serving the previous bundle
+				logger.Log("reload", "failed", "err", err)
+				continue
+			}
+			logger.Log("reload", "ok", "revision", reg.Revision())
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", pdp.NewHTTPHandler(reg))
+	// tenant policies are managed on the admin listener and evaluated under
+	// /tenant, e.g. /tenant/v1/evaluate with an X-Tenant-ID header
+	mux.Handle("/tenant/v1/", http.StripPrefix("/tenant", pdp.NewTenantHTTPHandler(tenants, prm.RealClock{})))
+	mux.Handle("/metrics", promhttp.Handler())
+
+	errc := make(chan error, 2)
+	go func() {
+		logger.Log("admin_listen_on", *adminListen)
+		errc <- http.ListenAndServe(*adminListen, pdp.NewAdminHandler(tenants))
+	}()
+	go func() {
+		logger.Log("listen_on", *listen)
+		errc <- http.ListenAndServe(*listen, mux)
+	}()
+	logger.Log("err", <-errc)
+	os.Exit(1)
+}
+
+func load(reg *pdp.Registry, path string) error {
+	bundle, err := prm.LoadBundle(path)
+	if err != nil {
+		return err
+	}
+	reg.Load(bundle)
+	return nil
+}
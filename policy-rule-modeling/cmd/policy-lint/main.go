@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+)
+
+// policy-lint reports shadowed, conflicting and unreachable rules in policy
+// bundles. It exits with status 1 when any finding has error severity.
+func main() {
+	asJSON := flag.Bool("json", false, "Print findings as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: policy-lint [-json] bundle.json...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var findings []prm.Finding
+	for _, path := range flag.Args() {
+		bundle, err := prm.LoadBundle(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		findings = append(findings, prm.AnalyzeBundle(bundle)...)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(findings); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	} else {
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+	}
+
+	for _, f := range findings {
+		if f.Severity == prm.SeverityError {
+			os.Exit(1)
+		}
+	}
+}
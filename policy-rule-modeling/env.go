@@ -0,0 +1,200 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// Environment describes the circumstances of a request rather than who makes
+// it or what it targets. Rules read it from the context passed to Matches.
+type Environment struct {
+	Clock     Clock
+	ClientIP  netip.Addr
+	RequestID string
+	Tenant    string
+}
+
+func (e Environment) Now() time.Time {
+	if e.Clock == nil {
+		return time.Now()
+	}
+	return e.Clock.Now()
+}
+
+type environmentContextKey struct{}
+
+func ContextWithEnvironment(ctx context.Context, env Environment) context.Context {
+	return context.WithValue(ctx, environmentContextKey{}, env)
+}
+
+// EnvironmentFromContext returns the zero Environment, which uses the real
+// clock, when none was set.
+func EnvironmentFromContext(ctx context.Context) Environment {
+	env, _ := ctx.Value(environmentContextKey{}).(Environment)
+	return env
+}
+
+// EnvironmentFromRequest takes the client IP from the connection and the
+// request ID and tenant from the X-Request-ID and X-Tenant-ID headers.
+func EnvironmentFromRequest(r *http.Request, clock Clock) Environment {
+	env := Environment{
+		Clock:     clock,
+		RequestID: r.Header.Get("X-Request-ID"),
+		Tenant:    r.Header.Get("X-Tenant-ID"),
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		env.ClientIP = ip.Unmap()
+	}
+	return env
+}
+
+// BusinessHoursRule matches requests made on one of Weekdays between Start
+// and End, both offsets from midnight in Location (UTC when nil).
+type BusinessHoursRule struct {
+	ID           string
+	RuleEffect   Effect
+	RulePriority int
+	Location     *time.Location
+	Weekdays     []time.Weekday
+	Start        time.Duration
+	End          time.Duration
+}
+
+func (r *BusinessHoursRule) Matches(ctx context.Context, _ Subject, _ Resource, _ Action) bool {
+	loc := r.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := EnvironmentFromContext(ctx).Now().In(loc)
+	if !slices.Contains(r.Weekdays, now.Weekday()) {
+		return false
+	}
+
+	// wall-clock offset, unlike now.Sub(midnight) on days with a DST change
+	offset := time.Duration(now.Hour())*time.Hour +
+		time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second +
+		time.Duration(now.Nanosecond())
+	return offset >= r.Start && offset < r.End
+}
+
+func (r *BusinessHoursRule) GetID() string {
+	return r.ID
+}
+
+func (r *BusinessHoursRule) Effect() Effect {
+	return r.RuleEffect
+}
+
+func (r *BusinessHoursRule) Priority() int {
+	return r.RulePriority
+}
+
+// IPRule matches requests whose client IP lies in one of Prefixes. Use
+// EffectAllow for an allow list and EffectDeny for a block list. Requests
+// without a known client IP never match.
+type IPRule struct {
+	ID           string
+	RuleEffect   Effect
+	RulePriority int
+	Prefixes     []netip.Prefix
+}
+
+func (r *IPRule) Matches(ctx context.Context, _ Subject, _ Resource, _ Action) bool {
+	ip := EnvironmentFromContext(ctx).ClientIP
+	if !ip.IsValid() {
+		return false
+	}
+	return slices.ContainsFunc(r.Prefixes, func(p netip.Prefix) bool { return p.Contains(ip) })
+}
+
+func (r *IPRule) GetID() string {
+	return r.ID
+}
+
+func (r *IPRule) Effect() Effect {
+	return r.RuleEffect
+}
+
+func (r *IPRule) Priority() int {
+	return r.RulePriority
+}
+
+// TenantIsolationRule denies access to resources of other tenants. It matches
+// when the request carries no tenant or the resource's Attribute ("tenant"
+// when empty) differs from it.
+type TenantIsolationRule struct {
+	ID           string
+	RulePriority int
+	Attribute    string
+}
+
+func (r *TenantIsolationRule) Matches(ctx context.Context, _ Subject, resource Resource, _ Action) bool {
+	attr := r.Attribute
+	if attr == "" {
+		attr = "tenant"
+	}
+	tenant := EnvironmentFromContext(ctx).Tenant
+	owner, _ := resource.GetAttributes()[attr].(string)
+	return tenant == "" || owner != tenant
+}
+
+func (r *TenantIsolationRule) GetID() string {
+	return r.ID
+}
+
+func (*TenantIsolationRule) Effect() Effect {
+	return EffectDeny
+}
+
+func (r *TenantIsolationRule) Priority() int {
+	return r.RulePriority
+}
+
+// AllOfRule matches when every one of Rules matches, regardless of their own
+// effects, e.g. to combine a role check with a time window and an IP range.
+type AllOfRule struct {
+	ID           string
+	RuleEffect   Effect
+	RulePriority int
+	Rules        []Rule
+	Obligations  []Obligation
+	Advice       []Obligation
+}
+
+func (r *AllOfRule) Matches(ctx context.Context, subject Subject, resource Resource, action Action) bool {
+	for _, rule := range r.Rules {
+		if !rule.Matches(ctx, subject, resource, action) {
+			return false
+		}
+	}
+	return len(r.Rules) > 0
+}
+
+func (r *AllOfRule) GetID() string {
+	return r.ID
+}
+
+func (r *AllOfRule) Effect() Effect {
+	return r.RuleEffect
+}
+
+func (r *AllOfRule) Priority() int {
+	return r.RulePriority
+}
+
+func (r *AllOfRule) GetObligations() []Obligation {
+	return r.Obligations
+}
+
+func (r *AllOfRule) GetAdvice() []Obligation {
+	return r.Advice
+}
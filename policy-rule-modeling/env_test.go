@@ -0,0 +1,126 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestEnvironmentRules(t *testing.T) {
+	office := netip.MustParsePrefix("10.0.0.0/8")
+	// admins may only delete during business hours from the office network
+	policy := &SimplePolicy{
+		ID:   "admin-delete",
+		Name: "Admin Delete Policy",
+		Rules: []Rule{
+			&TenantIsolationRule{ID: "tenant-isolation"},
+			&AllOfRule{
+				ID:         "admin-delete-office-hours",
+				RuleEffect: EffectAllow,
+				Rules: []Rule{
+					&AttributeRule{ID: "admin-delete", Actions: []string{"delete"}, Conditions: []Condition{
+						{Attribute: "subject.role", Operator: OpEquals, Value: "admin"},
+					}},
+					&BusinessHoursRule{
+						ID:       "business-hours",
+						Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+						Start:    9 * time.Hour,
+						End:      17 * time.Hour,
+					},
+					&IPRule{ID: "office-network", Prefixes: []netip.Prefix{office}},
+				},
+			},
+		},
+	}
+
+	admin := SimpleSubject{ID: "admin123", Attributes: map[string]interface{}{"role": "admin"}}
+	doc := SimpleResource{Type: "document", ID: "doc1", Attributes: map[string]interface{}{"tenant": "acme"}}
+	del := SimpleAction{Name: "delete"}
+	mondayNoon := time.Date(2025, time.June, 30, 12, 0, 0, 0, time.UTC)
+	mondayNight := time.Date(2025, time.June, 30, 22, 0, 0, 0, time.UTC)
+	saturdayNoon := time.Date(2025, time.July, 5, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		ip     string
+		tenant string
+		allow  bool
+	}{
+		{"allows during business hours from office", mondayNoon, "10.1.2.3", "acme", true},
+		{"denies outside business hours", mondayNight, "10.1.2.3", "acme", false},
+		{"denies on weekends", saturdayNoon, "10.1.2.3", "acme", false},
+		{"denies outside office network", mondayNoon, "203.0.113.7", "acme", false},
+		{"denies other tenant", mondayNoon, "10.1.2.3", "globex", false},
+		{"denies without tenant", mondayNoon, "10.1.2.3", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := ContextWithEnvironment(context.Background(), Environment{
+				Clock:    &fakeClock{now: tt.now},
+				ClientIP: netip.MustParseAddr(tt.ip),
+				Tenant:   tt.tenant,
+			})
+
+			decision := policy.Evaluate(ctx, admin, doc, del)
+
+			if decision.Allow != tt.allow {
+				t.Errorf("Expected Allow=%v, got %+v", tt.allow, decision)
+			}
+		})
+	}
+}
+
+func TestBusinessHoursRule_DSTChange(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	rule := &BusinessHoursRule{
+		ID:       "business-hours",
+		Location: loc,
+		Weekdays: []time.Weekday{time.Sunday},
+		Start:    9 * time.Hour,
+		End:      17 * time.Hour,
+	}
+	// clocks jump from 2:00 to 3:00 on this Sunday, so 9:00 is only 8h after midnight
+	dstStart := time.Date(2025, time.March, 9, 0, 0, 0, 0, loc)
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		match bool
+	}{
+		{"matches at opening", dstStart.Add(8 * time.Hour), true},
+		{"does not match before opening", dstStart.Add(7*time.Hour + 59*time.Minute), false},
+		{"does not match at closing", dstStart.Add(16 * time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := ContextWithEnvironment(context.Background(), Environment{Clock: &fakeClock{now: tt.now}})
+			if got := rule.Matches(ctx, nil, nil, nil); got != tt.match {
+				t.Errorf("Expected match=%v at %v, got %v", tt.match, tt.now.In(loc), got)
+			}
+		})
+	}
+}
+
+func TestEnvironmentFromRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/documents/doc1", nil)
+	r.RemoteAddr = "[::ffff:10.1.2.3]:54321"
+	r.Header.Set("X-Request-ID", "req-1")
+	r.Header.Set("X-Tenant-ID", "acme")
+
+	env := EnvironmentFromRequest(r, nil)
+
+	if env.ClientIP != netip.MustParseAddr("10.1.2.3") {
+		t.Errorf("Expected client IP 10.1.2.3, got %v", env.ClientIP)
+	}
+	if env.RequestID != "req-1" || env.Tenant != "acme" {
+		t.Errorf("Expected request ID and tenant from headers, got %+v", env)
+	}
+}
@@ -0,0 +1,268 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrGrantNotFound   = errors.New("grant not found")
+	ErrGrantExists     = errors.New("grant already exists")
+	ErrGrantInactive   = errors.New("grant is not active")
+	ErrNotDelegable    = errors.New("grant cannot be delegated")
+	ErrDelegationDepth = errors.New("delegation chain too long")
+	ErrNotGrantee      = errors.New("only the grantee can delegate a grant")
+	ErrWidensGrant     = errors.New("delegation exceeds the delegated grant")
+)
+
+// Grant gives Grantee Actions on a resource, or on every resource of
+// ResourceType when ResourceID is empty, between NotBefore and NotAfter (zero
+// meaning unbounded). Root grants decide how they may be delegated:
+// MaxDelegationDepth limits the chain length and a non-Transitive grant can be
+// delegated once but not re-delegated.
+type Grant struct {
+	ID           string
+	Grantor      string
+	Grantee      string
+	ResourceType string
+	ResourceID   string
+	Actions      []string
+	NotBefore    time.Time
+	NotAfter     time.Time
+
+	MaxDelegationDepth int
+	Transitive         bool
+
+	// set on delegated grants
+	Parent string
+	Depth  int
+}
+
+func (g *Grant) activeAt(now time.Time) bool {
+	return (g.NotBefore.IsZero() || !now.Before(g.NotBefore)) &&
+		(g.NotAfter.IsZero() || now.Before(g.NotAfter))
+}
+
+func (g *Grant) covers(resource Resource, action string) bool {
+	return g.ResourceType == resource.GetType() &&
+		(g.ResourceID == "" || g.ResourceID == resource.GetID()) &&
+		slices.Contains(g.Actions, action)
+}
+
+type GrantStore struct {
+	clock Clock
+
+	mu     sync.RWMutex
+	nextID int
+	grants map[string]*Grant
+}
+
+func NewGrantStore(clock Clock) *GrantStore {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	return &GrantStore{clock: clock, grants: map[string]*Grant{}}
+}
+
+// Issue stores a root grant, assigning an ID when it has none. An ID already
+// in use is refused rather than replaced.
+func (s *GrantStore) Issue(g Grant) (Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.grants[g.ID]; ok && g.ID != "" {
+		return Grant{}, fmt.Errorf("%w: %s", ErrGrantExists, g.ID)
+	}
+	g.Parent, g.Depth = "", 0
+	return s.store(g), nil
+}
+
+// Delegate lets the grantee of parentID pass on a subset of its actions to
+// grantee, no longer than the parent grant lasts.
+func (s *GrantStore) Delegate(parentID, delegator, grantee string, actions []string, notAfter time.Time) (Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent, ok := s.grants[parentID]
+	if !ok {
+		return Grant{}, fmt.Errorf("%w: %s", ErrGrantNotFound, parentID)
+	}
+	if parent.Grantee != delegator {
+		return Grant{}, ErrNotGrantee
+	}
+	if !s.validAt(parent, s.clock.Now()) {
+		return Grant{}, fmt.Errorf("%w: %s", ErrGrantInactive, parentID)
+	}
+
+	root := s.root(parent)
+	switch {
+	case root.MaxDelegationDepth == 0:
+		return Grant{}, ErrNotDelegable
+	case parent.Depth > 0 && !root.Transitive:
+		return Grant{}, fmt.Errorf("%w: %s is not transitive", ErrNotDelegable, root.ID)
+	case parent.Depth+1 > root.MaxDelegationDepth:
+		return Grant{}, fmt.Errorf("%w: max depth %d", ErrDelegationDepth, root.MaxDelegationDepth)
+	}
+
+	for _, a := range actions {
+		if !slices.Contains(parent.Actions, a) {
+			return Grant{}, fmt.Errorf("%w: action %s", ErrWidensGrant, a)
+		}
+	}
+	if !parent.NotAfter.IsZero() && (notAfter.IsZero() || notAfter.After(parent.NotAfter)) {
+		notAfter = parent.NotAfter
+	}
+
+	return s.store(Grant{
+		Grantor:      delegator,
+		Grantee:      grantee,
+		ResourceType: parent.ResourceType,
+		ResourceID:   parent.ResourceID,
+		Actions:      slices.Clone(actions),
+		NotBefore:    parent.NotBefore,
+		NotAfter:     notAfter,
+		Parent:       parent.ID,
+		Depth:        parent.Depth + 1,
+	}), nil
+}
+
+// store must be called with mu held. Assigned IDs skip those issued by
+// callers.
+func (s *GrantStore) store(g Grant) Grant {
+	for g.ID == "" {
+		s.nextID++
+		if id := fmt.Sprintf("grant-%d", s.nextID); s.grants[id] == nil {
+			g.ID = id
+		}
+	}
+	s.grants[g.ID] = &g
+	return g
+}
+
+// Revoke removes a grant together with everything delegated from it.
+func (s *GrantStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.grants[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrGrantNotFound, id)
+	}
+	s.removeTree(id)
+	return nil
+}
+
+// removeTree must be called with mu held.
+func (s *GrantStore) removeTree(id string) {
+	delete(s.grants, id)
+	for childID, g := range s.grants {
+		if g.Parent == id {
+			s.removeTree(childID)
+		}
+	}
+}
+
+// Sweep removes expired grants and their delegations and returns how many
+// grants were removed.
+func (s *GrantStore) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	before := len(s.grants)
+	for id, g := range s.grants {
+		if !g.NotAfter.IsZero() && !now.Before(g.NotAfter) {
+			s.removeTree(id)
+		}
+	}
+	return before - len(s.grants)
+}
+
+// RunSweeper sweeps every interval until ctx is done.
+func (s *GrantStore) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
+
+func (s *GrantStore) Get(id string) (Grant, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.grants[id]
+	if !ok {
+		return Grant{}, false
+	}
+	return *g, true
+}
+
+// Find returns a grant giving subjectID action on resource now, by the
+// store's clock, whose whole delegation chain is still in place.
+func (s *GrantStore) Find(subjectID string, resource Resource, action string) (Grant, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := s.clock.Now()
+	for _, g := range s.grants {
+		if g.Grantee == subjectID && g.covers(resource, action) && s.validAt(g, now) {
+			return *g, true
+		}
+	}
+	return Grant{}, false
+}
+
+// validAt must be called with mu held.
+func (s *GrantStore) validAt(g *Grant, now time.Time) bool {
+	for {
+		if !g.activeAt(now) {
+			return false
+		}
+		if g.Parent == "" {
+			return true
+		}
+		parent, ok := s.grants[g.Parent]
+		if !ok {
+			return false
+		}
+		g = parent
+	}
+}
+
+// root must be called with mu held and g's chain intact.
+func (s *GrantStore) root(g *Grant) *Grant {
+	for g.Parent != "" {
+		g = s.grants[g.Parent]
+	}
+	return g
+}
+
+// GrantRule allows requests covered by an active grant. Like delegation and
+// sweeping, it goes by the clock given to the store.
+type GrantRule struct {
+	ID           string
+	RulePriority int
+	Store        *GrantStore
+}
+
+func (r *GrantRule) Matches(_ context.Context, subject Subject, resource Resource, action Action) bool {
+	_, ok := r.Store.Find(subject.GetID(), resource, action.GetName())
+	return ok
+}
+
+func (r *GrantRule) GetID() string {
+	return r.ID
+}
+
+func (*GrantRule) Effect() Effect {
+	return EffectAllow
+}
+
+func (r *GrantRule) Priority() int {
+	return r.RulePriority
+}
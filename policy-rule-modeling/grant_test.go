@@ -0,0 +1,118 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGrantStore(t *testing.T) {
+	start := time.Date(2025, time.June, 30, 9, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	store := NewGrantStore(clock)
+	doc := SimpleResource{Type: "document", ID: "doc1"}
+
+	rule := &GrantRule{ID: "grants", Store: store}
+	policy := &SimplePolicy{ID: "grants", Name: "Grant Policy", Rules: []Rule{rule}}
+	allowed := func(subjectID, action string) bool {
+		return policy.Evaluate(context.Background(), SimpleSubject{ID: subjectID}, doc, SimpleAction{Name: action}).Allow
+	}
+	issue := func(g Grant) Grant {
+		t.Helper()
+		issued, err := store.Issue(g)
+		if err != nil {
+			t.Fatalf("Expected grant to be issued, got %v", err)
+		}
+		return issued
+	}
+
+	onCall := issue(Grant{
+		Grantor:            "admin",
+		Grantee:            "alice",
+		ResourceType:       "document",
+		Actions:            []string{"read", "write"},
+		NotBefore:          start,
+		NotAfter:           start.Add(4 * time.Hour),
+		MaxDelegationDepth: 2,
+	})
+
+	t.Run("grants access within the window", func(t *testing.T) {
+		if !allowed("alice", "write") {
+			t.Errorf("Expected alice to write during on-call")
+		}
+		if allowed("alice", "delete") {
+			t.Errorf("Expected alice not to delete")
+		}
+	})
+
+	t.Run("delegates a subset of actions", func(t *testing.T) {
+		delegated, err := store.Delegate(onCall.ID, "alice", "bob", []string{"read"}, time.Time{})
+		if err != nil {
+			t.Fatalf("Expected delegation to succeed, got %v", err)
+		}
+		if !delegated.NotAfter.Equal(onCall.NotAfter) {
+			t.Errorf("Expected delegation to end with parent at %v, got %v", onCall.NotAfter, delegated.NotAfter)
+		}
+		if !allowed("bob", "read") || allowed("bob", "write") {
+			t.Errorf("Expected bob to read only")
+		}
+
+		if _, err := store.Delegate(delegated.ID, "bob", "carol", []string{"read"}, time.Time{}); !errors.Is(err, ErrNotDelegable) {
+			t.Errorf("Expected non-transitive grant to refuse re-delegation, got %v", err)
+		}
+		if _, err := store.Delegate(onCall.ID, "alice", "carol", []string{"delete"}, time.Time{}); !errors.Is(err, ErrWidensGrant) {
+			t.Errorf("Expected ErrWidensGrant, got %v", err)
+		}
+		if _, err := store.Delegate(onCall.ID, "bob", "carol", []string{"read"}, time.Time{}); !errors.Is(err, ErrNotGrantee) {
+			t.Errorf("Expected ErrNotGrantee, got %v", err)
+		}
+	})
+
+	t.Run("limits transitive chains to max depth", func(t *testing.T) {
+		root := issue(Grant{Grantee: "dave", ResourceType: "document", Actions: []string{"read"}, MaxDelegationDepth: 1, Transitive: true})
+		first, err := store.Delegate(root.ID, "dave", "erin", []string{"read"}, time.Time{})
+		if err != nil {
+			t.Fatalf("Expected delegation to succeed, got %v", err)
+		}
+		if _, err := store.Delegate(first.ID, "erin", "frank", []string{"read"}, time.Time{}); !errors.Is(err, ErrDelegationDepth) {
+			t.Errorf("Expected ErrDelegationDepth, got %v", err)
+		}
+	})
+
+	t.Run("revocation cascades to delegations", func(t *testing.T) {
+		root := issue(Grant{Grantee: "gina", ResourceType: "document", Actions: []string{"read"}, MaxDelegationDepth: 1})
+		if _, err := store.Delegate(root.ID, "gina", "hank", []string{"read"}, time.Time{}); err != nil {
+			t.Fatalf("Expected delegation to succeed, got %v", err)
+		}
+		if err := store.Revoke(root.ID); err != nil {
+			t.Fatalf("Expected revoke to succeed, got %v", err)
+		}
+		if allowed("gina", "read") || allowed("hank", "read") {
+			t.Errorf("Expected revoked grant and its delegation to stop granting")
+		}
+	})
+
+	t.Run("refuses duplicate ids", func(t *testing.T) {
+		named := issue(Grant{ID: "grant-100", Grantee: "ivan", ResourceType: "document", Actions: []string{"read"}})
+		if _, err := store.Issue(Grant{ID: named.ID, Grantee: "mallory", ResourceType: "document", Actions: []string{"write"}}); !errors.Is(err, ErrGrantExists) {
+			t.Errorf("Expected ErrGrantExists, got %v", err)
+		}
+		if g, _ := store.Get(named.ID); g.Grantee != "ivan" {
+			t.Errorf("Expected original grant to be kept, got %+v", g)
+		}
+	})
+
+	t.Run("expires and sweeps", func(t *testing.T) {
+		clock.now = start.Add(4 * time.Hour)
+		if allowed("alice", "read") || allowed("bob", "read") {
+			t.Errorf("Expected expired grants to stop granting")
+		}
+		if removed := store.Sweep(); removed != 2 {
+			t.Errorf("Expected on-call grant and its delegation to be swept, got %d", removed)
+		}
+		if _, ok := store.Get(onCall.ID); ok {
+			t.Errorf("Expected swept grant to be gone")
+		}
+	})
+}
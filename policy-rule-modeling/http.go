@@ -0,0 +1,175 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// ErrNoSubject is answered with 401 by go-kit's DefaultErrorEncoder.
+var ErrNoSubject error = unauthorizedError{errors.New("no subject in request")}
+
+type unauthorizedError struct {
+	error
+}
+
+func (unauthorizedError) StatusCode() int {
+	return http.StatusUnauthorized
+}
+
+// DeniedError carries the deny decision so transports can report its reason.
+// It implements go-kit's StatusCoder, so DefaultErrorEncoder answers 403.
+type DeniedError struct {
+	Decision Decision
+}
+
+func (e DeniedError) Error() string {
+	return "forbidden: " + e.Decision.Reason
+}
+
+func (DeniedError) StatusCode() int {
+	return http.StatusForbidden
+}
+
+type subjectContextKey struct{}
+
+func ContextWithSubject(ctx context.Context, subject Subject) context.Context {
+	return context.WithValue(ctx, subjectContextKey{}, subject)
+}
+
+func SubjectFromContext(ctx context.Context) (Subject, bool) {
+	subject, ok := ctx.Value(subjectContextKey{}).(Subject)
+	return subject, ok
+}
+
+// IdentifyFunc extracts the caller from a request, e.g. from a verified token.
+type IdentifyFunc func(r *http.Request) (Subject, error)
+
+// HeaderIdentity trusts the given headers to carry the subject ID and role.
+// It is only suitable behind a gateway that authenticates callers.
+func HeaderIdentity(idHeader, roleHeader string) IdentifyFunc {
+	return func(r *http.Request) (Subject, error) {
+		id := r.Header.Get(idHeader)
+		if id == "" {
+			return nil, ErrNoSubject
+		}
+		attrs := map[string]interface{}{}
+		if role := r.Header.Get(roleHeader); role != "" {
+			attrs["role"] = role
+		}
+		return SimpleSubject{ID: id, Attributes: attrs}, nil
+	}
+}
+
+// Route maps a request to the resource and action it operates on. A Path
+// ending in "/" matches as a prefix and the remainder becomes the resource ID.
+type Route struct {
+	Method       string
+	Path         string
+	ResourceType string
+	Action       string
+}
+
+func (rt Route) match(r *http.Request) (Resource, Action, bool) {
+	if rt.Method != "" && rt.Method != r.Method {
+		return nil, nil, false
+	}
+
+	var id string
+	switch {
+	case strings.HasSuffix(rt.Path, "/") && strings.HasPrefix(r.URL.Path, rt.Path):
+		id = strings.TrimPrefix(r.URL.Path, rt.Path)
+	case rt.Path == r.URL.Path:
+	default:
+		return nil, nil, false
+	}
+
+	return SimpleResource{Type: rt.ResourceType, ID: id}, SimpleAction{Name: rt.Action}, true
+}
+
+// HTTPMiddleware authorizes each request against policy. Requests without an
+// identity get 401, requests matching no route or denied by policy get 403.
+// Unless an earlier middleware set one, the request Environment is added to
+// the context.
+func HTTPMiddleware(policy Policy, identify IdentifyFunc, routes []Route) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			subject, err := identify(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			resource, action, ok := matchRoute(routes, r)
+			if !ok {
+				http.Error(w, "forbidden: no route", http.StatusForbidden)
+				return
+			}
+
+			ctx := ContextWithSubject(r.Context(), subject)
+			if _, ok := ctx.Value(environmentContextKey{}).(Environment); !ok {
+				ctx = ContextWithEnvironment(ctx, EnvironmentFromRequest(r, RealClock{}))
+			}
+			decision := policy.Evaluate(ctx, subject, resource, action)
+			if !decision.Allow {
+				http.Error(w, DeniedError{decision}.Error(), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func matchRoute(routes []Route, r *http.Request) (Resource, Action, bool) {
+	for _, rt := range routes {
+		if resource, action, ok := rt.match(r); ok {
+			return resource, action, true
+		}
+	}
+	return nil, nil, false
+}
+
+// IdentityToContext is a go-kit ServerBefore hook placing the caller into the
+// context for EndpointMiddleware. Failed identification leaves it unset.
+func IdentityToContext(identify IdentifyFunc) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		subject, err := identify(r)
+		if err != nil {
+			return ctx
+		}
+		return ContextWithSubject(ctx, subject)
+	}
+}
+
+// EnvironmentToContext is a go-kit ServerBefore hook placing the request
+// environment into the context for environment-aware rules.
+func EnvironmentToContext(clock Clock) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		return ContextWithEnvironment(ctx, EnvironmentFromRequest(r, clock))
+	}
+}
+
+// EndpointMiddleware guards a go-kit endpoint operating on resource with
+// action. The subject is taken from the context, see IdentityToContext.
+func EndpointMiddleware(policy Policy, resource Resource, action Action) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			subject, ok := SubjectFromContext(ctx)
+			if !ok {
+				return nil, ErrNoSubject
+			}
+
+			decision := policy.Evaluate(ctx, subject, resource, action)
+			if !decision.Allow {
+				return nil, DeniedError{decision}
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
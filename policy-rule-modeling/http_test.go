@@ -0,0 +1,113 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newDocumentPolicy() Policy {
+	return &SimplePolicy{
+		ID:   "document-policy",
+		Name: "Document Policy",
+		Rules: []Rule{&MockRule{
+			ID:         "admin-delete",
+			RuleEffect: EffectAllow,
+			MatchFunc: func(ctx context.Context, subject Subject, resource Resource, action Action) bool {
+				role, _ := subject.GetAttributes()["role"].(string)
+				return action.GetName() != "delete" || role == "admin"
+			},
+		}},
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	routes := []Route{
+		{Method: http.MethodGet, Path: "/documents/", ResourceType: "document", Action: "read"},
+		{Method: http.MethodDelete, Path: "/documents/", ResourceType: "document", Action: "delete"},
+	}
+	var gotSubject Subject
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSubject, _ = SubjectFromContext(r.Context())
+	})
+	handler := HTTPMiddleware(newDocumentPolicy(), HeaderIdentity("X-User-ID", "X-User-Role"), routes)(next)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		userID   string
+		role     string
+		wantCode int
+	}{
+		{"allows read", http.MethodGet, "/documents/doc1", "user123", "user", http.StatusOK},
+		{"denies delete for non-admin", http.MethodDelete, "/documents/doc1", "user123", "user", http.StatusForbidden},
+		{"allows delete for admin", http.MethodDelete, "/documents/doc1", "admin123", "admin", http.StatusOK},
+		{"denies unknown route", http.MethodPost, "/documents/doc1", "admin123", "admin", http.StatusForbidden},
+		{"rejects anonymous", http.MethodGet, "/documents/doc1", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSubject = nil
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			r.Header.Set("X-User-ID", tt.userID)
+			r.Header.Set("X-User-Role", tt.role)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if tt.wantCode == http.StatusOK && (gotSubject == nil || gotSubject.GetID() != tt.userID) {
+				t.Errorf("Expected subject %s in handler context, got %v", tt.userID, gotSubject)
+			}
+		})
+	}
+
+	t.Run("reports decision reason on deny", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodDelete, "/documents/doc1", nil)
+		r.Header.Set("X-User-ID", "user123")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		if !strings.Contains(w.Body.String(), "no matching rules") {
+			t.Errorf("Expected decision reason in body, got %q", w.Body.String())
+		}
+	})
+}
+
+func TestEndpointMiddleware(t *testing.T) {
+	e := EndpointMiddleware(newDocumentPolicy(), SimpleResource{Type: "document"}, SimpleAction{Name: "delete"})(
+		func(ctx context.Context, request interface{}) (interface{}, error) {
+			return "deleted", nil
+		},
+	)
+
+	t.Run("allows admin", func(t *testing.T) {
+		ctx := ContextWithSubject(context.Background(), SimpleSubject{ID: "admin123", Attributes: map[string]interface{}{"role": "admin"}})
+		if _, err := e(ctx, nil); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("denies non-admin with 403", func(t *testing.T) {
+		ctx := ContextWithSubject(context.Background(), SimpleSubject{ID: "user123"})
+		_, err := e(ctx, nil)
+		var denied DeniedError
+		if !errors.As(err, &denied) || denied.StatusCode() != http.StatusForbidden {
+			t.Errorf("Expected DeniedError, got %v", err)
+		}
+	})
+
+	t.Run("rejects missing subject", func(t *testing.T) {
+		if _, err := e(context.Background(), nil); !errors.Is(err, ErrNoSubject) {
+			t.Errorf("Expected ErrNoSubject, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// DecisionFieldKeys are the label names used by InstrumentingPolicy.
+var DecisionFieldKeys = []string{"policy_id", "effect", "matched_rule"}
+
+type InstrumentingPolicy struct {
+	decisionCount   metrics.Counter
+	decisionLatency metrics.Histogram
+	next            Policy
+}
+
+// NewInstrumentingPolicy counts decisions and observes evaluation latency in
+// seconds, labelled with DecisionFieldKeys.
+func NewInstrumentingPolicy(next Policy, decisionCount metrics.Counter, decisionLatency metrics.Histogram) *InstrumentingPolicy {
+	return &InstrumentingPolicy{decisionCount, decisionLatency, next}
+}
+
+func (p *InstrumentingPolicy) Evaluate(ctx context.Context, subject Subject, resource Resource, action Action) (decision Decision) {
+	defer func(begin time.Time) {
+		lvs := []string{"policy_id", p.next.GetID(), "effect", effectLabel(decision), "matched_rule", decision.RuleID}
+		p.decisionCount.With(lvs...).Add(1)
+		p.decisionLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	decision = p.next.Evaluate(ctx, subject, resource, action)
+	return
+}
+
+func (p *InstrumentingPolicy) GetID() string {
+	return p.next.GetID()
+}
+
+func (p *InstrumentingPolicy) GetName() string {
+	return p.next.GetName()
+}
+
+func effectLabel(d Decision) string {
+	if d.Allow {
+		return EffectAllow.String()
+	}
+	return EffectDeny.String()
+}
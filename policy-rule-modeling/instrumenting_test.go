@@ -0,0 +1,83 @@
+package policyrulemodeling
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/log"
+)
+
+type recordedMetrics struct {
+	values map[string]float64
+}
+
+type fakeMetric struct {
+	recorded *recordedMetrics
+	lvs      []string
+}
+
+func (m fakeMetric) With(labelValues ...string) metrics.Counter {
+	return fakeMetric{m.recorded, append(append([]string{}, m.lvs...), labelValues...)}
+}
+
+func (m fakeMetric) Add(delta float64) {
+	m.recorded.values[strings.Join(m.lvs, ",")] += delta
+}
+
+type fakeHistogram struct {
+	fakeMetric
+}
+
+func (h fakeHistogram) With(labelValues ...string) metrics.Histogram {
+	return fakeHistogram{h.fakeMetric.With(labelValues...).(fakeMetric)}
+}
+
+func (h fakeHistogram) Observe(float64) {
+	h.Add(1)
+}
+
+func TestInstrumentingPolicy(t *testing.T) {
+	ctx := context.Background()
+	counts := &recordedMetrics{values: map[string]float64{}}
+	observations := &recordedMetrics{values: map[string]float64{}}
+	policy := NewInstrumentingPolicy(newDocumentPolicy(), fakeMetric{recorded: counts}, fakeHistogram{fakeMetric{recorded: observations}})
+
+	admin := SimpleSubject{ID: "admin123", Attributes: map[string]interface{}{"role": "admin"}}
+	user := SimpleSubject{ID: "user123", Attributes: map[string]interface{}{"role": "user"}}
+	doc := SimpleResource{Type: "document", ID: "doc1"}
+	policy.Evaluate(ctx, admin, doc, SimpleAction{Name: "delete"})
+	policy.Evaluate(ctx, user, doc, SimpleAction{Name: "read"})
+	policy.Evaluate(ctx, user, doc, SimpleAction{Name: "delete"})
+
+	allowKey := "policy_id,document-policy,effect,allow,matched_rule,admin-delete"
+	denyKey := "policy_id,document-policy,effect,deny,matched_rule,"
+	if counts.values[allowKey] != 2 || counts.values[denyKey] != 1 {
+		t.Errorf("Expected 2 allows and 1 deny, got %v", counts.values)
+	}
+	if observations.values[allowKey] != 2 || observations.values[denyKey] != 1 {
+		t.Errorf("Expected a latency observation per decision, got %v", observations.values)
+	}
+}
+
+func TestLoggingPolicy(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	policy := NewLoggingPolicy(newDocumentPolicy(), log.NewLogfmtLogger(&buf), 3)
+
+	user := SimpleSubject{ID: "user123", Attributes: map[string]interface{}{"role": "user"}}
+	doc := SimpleResource{Type: "document", ID: "doc1"}
+	for range 6 {
+		policy.Evaluate(ctx, user, doc, SimpleAction{Name: "read"})
+	}
+	policy.Evaluate(ctx, user, doc, SimpleAction{Name: "delete"})
+
+	if allows := strings.Count(buf.String(), "effect=allow"); allows != 2 {
+		t.Errorf("Expected 2 of 6 allows to be sampled, got %d", allows)
+	}
+	if denies := strings.Count(buf.String(), "effect=deny"); denies != 1 {
+		t.Errorf("Expected every deny to be logged, got %d", denies)
+	}
+}
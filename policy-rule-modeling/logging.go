@@ -0,0 +1,60 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+// LoggingPolicy logs every deny and one in allowSampleEvery allows, since
+// allows dominate traffic and are rarely interesting.
+type LoggingPolicy struct {
+	logger           log.Logger
+	allowSampleEvery uint64
+	allows           atomic.Uint64
+	next             Policy
+}
+
+// NewLoggingPolicy logs all allows when allowSampleEvery is 0 or 1.
+func NewLoggingPolicy(next Policy, logger log.Logger, allowSampleEvery uint64) *LoggingPolicy {
+	return &LoggingPolicy{logger: logger, allowSampleEvery: allowSampleEvery, next: next}
+}
+
+func (p *LoggingPolicy) Evaluate(ctx context.Context, subject Subject, resource Resource, action Action) (decision Decision) {
+	defer func(begin time.Time) {
+		if decision.Allow && !p.sampleAllow() {
+			return
+		}
+		p.logger.Log(
+			"policy_id", p.next.GetID(),
+			"subject", subject.GetID(),
+			"resource_type", resource.GetType(),
+			"resource_id", resource.GetID(),
+			"action", action.GetName(),
+			"effect", effectLabel(decision),
+			"reason", decision.Reason,
+			"matched_rule", decision.RuleID,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	decision = p.next.Evaluate(ctx, subject, resource, action)
+	return
+}
+
+func (p *LoggingPolicy) sampleAllow() bool {
+	if p.allowSampleEvery <= 1 {
+		return true
+	}
+	return (p.allows.Add(1)-1)%p.allowSampleEvery == 0
+}
+
+func (p *LoggingPolicy) GetID() string {
+	return p.next.GetID()
+}
+
+func (p *LoggingPolicy) GetName() string {
+	return p.next.GetName()
+}
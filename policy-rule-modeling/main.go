@@ -2,6 +2,7 @@ package policyrulemodeling
 
 import (
 	"context"
+	"fmt"
 	"strings"
 )
 
@@ -19,9 +20,15 @@ type Rule interface {
 }
 
 type Decision struct {
-	Allow     bool
-	Reason    string
-	MatchedBy string // which rule/policy made the decision
+	Allow     bool   `json:"allow"`
+	Reason    string `json:"reason"`
+	MatchedBy string `json:"matched_by,omitempty"` // which rule/policy made the decision
+	RuleID    string `json:"rule_id,omitempty"`    // the deciding rule, when a single rule decided
+
+	// Obligations must be fulfilled by the enforcement point for the decision
+	// to stand, Advice may be ignored. See ObligationRegistry.
+	Obligations []Obligation `json:"obligations,omitempty"`
+	Advice      []Obligation `json:"advice,omitempty"`
 }
 
 type Effect int
@@ -31,6 +38,33 @@ const (
 	EffectDeny
 )
 
+func (e Effect) String() string {
+	switch e {
+	case EffectAllow:
+		return "allow"
+	case EffectDeny:
+		return "deny"
+	default:
+		return fmt.Sprintf("Effect(%d)", int(e))
+	}
+}
+
+func (e Effect) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+func (e *Effect) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "allow":
+		*e = EffectAllow
+	case "deny":
+		*e = EffectDeny
+	default:
+		return fmt.Errorf("unknown effect %q", text)
+	}
+	return nil
+}
+
 type Subject interface {
 	GetID() string
 	GetAttributes() map[string]interface{}
@@ -46,6 +80,45 @@ type Action interface {
 	GetName() string
 }
 
+type SimpleSubject struct {
+	ID         string                 `json:"id"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+func (s SimpleSubject) GetID() string {
+	return s.ID
+}
+
+func (s SimpleSubject) GetAttributes() map[string]interface{} {
+	return s.Attributes
+}
+
+type SimpleResource struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+func (r SimpleResource) GetType() string {
+	return r.Type
+}
+
+func (r SimpleResource) GetID() string {
+	return r.ID
+}
+
+func (r SimpleResource) GetAttributes() map[string]interface{} {
+	return r.Attributes
+}
+
+type SimpleAction struct {
+	Name string `json:"name"`
+}
+
+func (a SimpleAction) GetName() string {
+	return a.Name
+}
+
 type SimplePolicy struct {
 	ID    string
 	Name  string
@@ -55,11 +128,12 @@ type SimplePolicy struct {
 func (p *SimplePolicy) Evaluate(ctx context.Context, subject Subject, resource Resource, action Action) Decision {
 	for _, rule := range p.Rules {
 		if rule.Matches(ctx, subject, resource, action) {
-			return Decision{
+			return attachObligations(Decision{
 				Allow:     rule.Effect() == EffectAllow,
 				Reason:    p.Name,
 				MatchedBy: p.ID,
-			}
+				RuleID:    rule.GetID(),
+			}, rule)
 		}
 	}
 	return Decision{Allow: false, Reason: "no matching rules"}
@@ -92,26 +166,34 @@ func (p *AllMustAllowPolicy) Evaluate(ctx context.Context, subject Subject, reso
 		return Decision{Allow: false, Reason: "no matching rules"}
 	}
 
-	deniedBy := []string{}
+	deniedBy := []Rule{}
 	for _, rule := range matchedRules {
 		if rule.Effect() != EffectAllow {
-			deniedBy = append(deniedBy, rule.GetID())
+			deniedBy = append(deniedBy, rule)
 		}
 	}
 
 	if len(deniedBy) > 0 {
-		return Decision{
+		return attachObligations(Decision{
 			Allow:     false,
-			Reason:    "denied by rules: " + strings.Join(deniedBy, ", "),
+			Reason:    "denied by rules: " + strings.Join(getRuleIDs(deniedBy), ", "),
 			MatchedBy: p.ID,
-		}
+		}, deniedBy...)
 	}
 
-	return Decision{
+	return attachObligations(Decision{
 		Allow:     true,
 		Reason:    "all rules allowed: " + strings.Join(getRuleIDs(matchedRules), ", "),
 		MatchedBy: p.ID,
-	}
+	}, matchedRules...)
+}
+
+func (p *AllMustAllowPolicy) GetID() string {
+	return p.ID
+}
+
+func (p *AllMustAllowPolicy) GetName() string {
+	return p.Name
 }
 
 func getRuleIDs(rules []Rule) []string {
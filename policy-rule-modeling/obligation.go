@@ -0,0 +1,106 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrObligationNotFulfilled = errors.New("obligation not fulfilled")
+
+// Obligation is an instruction attached to a decision, e.g. ID "mask-field"
+// with Attributes {"field": "email"}, or ID "audit".
+type Obligation struct {
+	ID         string                 `json:"id"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// ObligationRule is implemented by rules that attach obligations and advice
+// to the decisions they contribute to.
+type ObligationRule interface {
+	Rule
+	GetObligations() []Obligation
+	GetAdvice() []Obligation
+}
+
+func attachObligations(decision Decision, rules ...Rule) Decision {
+	for _, rule := range rules {
+		if r, ok := rule.(ObligationRule); ok {
+			decision.Obligations = append(decision.Obligations, r.GetObligations()...)
+			decision.Advice = append(decision.Advice, r.GetAdvice()...)
+		}
+	}
+	return decision
+}
+
+type ObligationHandler func(ctx context.Context, obligation Obligation, decision Decision) error
+
+// ObligationRegistry is used by enforcement points to fulfil obligations.
+type ObligationRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string]ObligationHandler
+}
+
+func NewObligationRegistry() *ObligationRegistry {
+	return &ObligationRegistry{handlers: map[string]ObligationHandler{}}
+}
+
+func (r *ObligationRegistry) Register(id string, handler ObligationHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[id] = handler
+}
+
+// Fulfil runs the handler of every obligation and advice in decision. An
+// obligation without a handler or whose handler fails turns the decision into
+// a deny and is returned as an error. Advice is best effort.
+func (r *ObligationRegistry) Fulfil(ctx context.Context, decision Decision) (Decision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, o := range decision.Obligations {
+		handler, ok := r.handlers[o.ID]
+		if !ok {
+			return failClosed(decision, fmt.Errorf("%w: no handler for %s", ErrObligationNotFulfilled, o.ID))
+		}
+		if err := handler(ctx, o, decision); err != nil {
+			return failClosed(decision, fmt.Errorf("%w: %s: %w", ErrObligationNotFulfilled, o.ID, err))
+		}
+	}
+
+	for _, a := range decision.Advice {
+		if handler, ok := r.handlers[a.ID]; ok {
+			_ = handler(ctx, a, decision)
+		}
+	}
+	return decision, nil
+}
+
+func failClosed(decision Decision, err error) (Decision, error) {
+	return Decision{Allow: false, Reason: err.Error(), MatchedBy: decision.MatchedBy}, err
+}
+
+// EnforcingPolicy fulfils the obligations of every decision of the wrapped
+// policy, so transports built on Policy, like HTTPMiddleware, enforce them.
+type EnforcingPolicy struct {
+	next     Policy
+	registry *ObligationRegistry
+}
+
+func NewEnforcingPolicy(next Policy, registry *ObligationRegistry) *EnforcingPolicy {
+	return &EnforcingPolicy{next: next, registry: registry}
+}
+
+func (p *EnforcingPolicy) Evaluate(ctx context.Context, subject Subject, resource Resource, action Action) Decision {
+	decision, _ := p.registry.Fulfil(ctx, p.next.Evaluate(ctx, subject, resource, action))
+	return decision
+}
+
+func (p *EnforcingPolicy) GetID() string {
+	return p.next.GetID()
+}
+
+func (p *EnforcingPolicy) GetName() string {
+	return p.next.GetName()
+}
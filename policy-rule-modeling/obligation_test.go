@@ -0,0 +1,80 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestObligations(t *testing.T) {
+	ctx := context.Background()
+	subject := SimpleSubject{ID: "user123", Attributes: map[string]interface{}{"role": "support"}}
+	resource := SimpleResource{Type: "customer", ID: "cust1"}
+	read := SimpleAction{Name: "read"}
+
+	maskEmail := Obligation{ID: "mask-field", Attributes: map[string]interface{}{"field": "email"}}
+	audit := Obligation{ID: "audit"}
+	supportRead := &AttributeRule{
+		ID:          "support-read",
+		RuleEffect:  EffectAllow,
+		Actions:     []string{"read"},
+		Conditions:  []Condition{{Attribute: "subject.role", Operator: OpEquals, Value: "support"}},
+		Obligations: []Obligation{maskEmail},
+		Advice:      []Obligation{audit},
+	}
+
+	t.Run("simple policy attaches obligations of matched rule", func(t *testing.T) {
+		policy := &SimplePolicy{ID: "customers", Name: "Customer Policy", Rules: []Rule{supportRead}}
+		decision := policy.Evaluate(ctx, subject, resource, read)
+		if len(decision.Obligations) != 1 || decision.Obligations[0].ID != "mask-field" {
+			t.Errorf("Expected mask-field obligation, got %+v", decision.Obligations)
+		}
+		if len(decision.Advice) != 1 || decision.Advice[0].ID != "audit" {
+			t.Errorf("Expected audit advice, got %+v", decision.Advice)
+		}
+	})
+
+	t.Run("all-must-allow policy collects obligations of all allowing rules", func(t *testing.T) {
+		logged := &AttributeRule{ID: "log-reads", RuleEffect: EffectAllow, Actions: []string{"read"}, Obligations: []Obligation{audit}}
+		policy := &AllMustAllowPolicy{ID: "customers", Name: "Customer Policy", Rules: []Rule{supportRead, logged}}
+		decision := policy.Evaluate(ctx, subject, resource, read)
+		if !decision.Allow || len(decision.Obligations) != 2 {
+			t.Errorf("Expected allow with 2 obligations, got %+v", decision)
+		}
+	})
+
+	t.Run("enforcement fulfils obligations", func(t *testing.T) {
+		var masked string
+		registry := NewObligationRegistry()
+		registry.Register("mask-field", func(ctx context.Context, o Obligation, d Decision) error {
+			masked, _ = o.Attributes["field"].(string)
+			return nil
+		})
+		policy := NewEnforcingPolicy(&SimplePolicy{ID: "customers", Name: "Customer Policy", Rules: []Rule{supportRead}}, registry)
+
+		decision := policy.Evaluate(ctx, subject, resource, read)
+
+		if !decision.Allow || masked != "email" {
+			t.Errorf("Expected allow with email masked, got %+v, masked %q", decision, masked)
+		}
+	})
+
+	t.Run("enforcement fails closed without handler", func(t *testing.T) {
+		policy := &SimplePolicy{ID: "customers", Name: "Customer Policy", Rules: []Rule{supportRead}}
+		decision, err := NewObligationRegistry().Fulfil(ctx, policy.Evaluate(ctx, subject, resource, read))
+		if decision.Allow || !errors.Is(err, ErrObligationNotFulfilled) {
+			t.Errorf("Expected deny with ErrObligationNotFulfilled, got %+v, %v", decision, err)
+		}
+	})
+
+	t.Run("enforcement fails closed when handler fails", func(t *testing.T) {
+		registry := NewObligationRegistry()
+		registry.Register("mask-field", func(ctx context.Context, o Obligation, d Decision) error {
+			return errors.New("unknown field")
+		})
+		policy := NewEnforcingPolicy(&SimplePolicy{ID: "customers", Name: "Customer Policy", Rules: []Rule{supportRead}}, registry)
+		if decision := policy.Evaluate(ctx, subject, resource, read); decision.Allow {
+			t.Errorf("Expected deny when obligation handler fails, got %+v", decision)
+		}
+	})
+}
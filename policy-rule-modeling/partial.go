@@ -0,0 +1,252 @@
+package policyrulemodeling
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrNotPartiallyEvaluable = errors.New("condition cannot be partially evaluated")
+
+type FilterOp string
+
+const (
+	FilterTrue  FilterOp = "true"
+	FilterFalse FilterOp = "false"
+	FilterCond  FilterOp = "cond"
+	FilterAnd   FilterOp = "and"
+	FilterOr    FilterOp = "or"
+	FilterNot   FilterOp = "not"
+)
+
+// Filter is a boolean expression over resource attributes. Leaves are
+// Conditions whose Attribute is a resource path and whose ValueFrom, if set,
+// is a resource path too. Repositories can translate it into a query or
+// apply Matches to loaded resources.
+type Filter struct {
+	Op       FilterOp
+	Cond     Condition
+	Children []Filter
+}
+
+var (
+	matchAll  = Filter{Op: FilterTrue}
+	matchNone = Filter{Op: FilterFalse}
+)
+
+func condFilter(c Condition) Filter {
+	return Filter{Op: FilterCond, Cond: c}
+}
+
+func andFilter(filters ...Filter) Filter {
+	var children []Filter
+	for _, f := range filters {
+		switch f.Op {
+		case FilterFalse:
+			return matchNone
+		case FilterTrue:
+		case FilterAnd:
+			children = append(children, f.Children...)
+		default:
+			children = append(children, f)
+		}
+	}
+	switch len(children) {
+	case 0:
+		return matchAll
+	case 1:
+		return children[0]
+	default:
+		return Filter{Op: FilterAnd, Children: children}
+	}
+}
+
+func orFilter(filters ...Filter) Filter {
+	var children []Filter
+	for _, f := range filters {
+		switch f.Op {
+		case FilterTrue:
+			return matchAll
+		case FilterFalse:
+		case FilterOr:
+			children = append(children, f.Children...)
+		default:
+			children = append(children, f)
+		}
+	}
+	switch len(children) {
+	case 0:
+		return matchNone
+	case 1:
+		return children[0]
+	default:
+		return Filter{Op: FilterOr, Children: children}
+	}
+}
+
+func notFilter(f Filter) Filter {
+	switch f.Op {
+	case FilterTrue:
+		return matchNone
+	case FilterFalse:
+		return matchAll
+	case FilterNot:
+		return f.Children[0]
+	default:
+		return Filter{Op: FilterNot, Children: []Filter{f}}
+	}
+}
+
+// MatchesNothing reports whether the subject may access no resource at all,
+// letting callers skip the query.
+func (f Filter) MatchesNothing() bool {
+	return f.Op == FilterFalse
+}
+
+func (f Filter) Matches(resource Resource) bool {
+	switch f.Op {
+	case FilterTrue:
+		return true
+	case FilterCond:
+		return f.Cond.Matches(SimpleSubject{}, resource, SimpleAction{})
+	case FilterAnd:
+		for _, c := range f.Children {
+			if !c.Matches(resource) {
+				return false
+			}
+		}
+		return true
+	case FilterOr:
+		for _, c := range f.Children {
+			if c.Matches(resource) {
+				return true
+			}
+		}
+		return false
+	case FilterNot:
+		return !f.Children[0].Matches(resource)
+	default:
+		return false
+	}
+}
+
+func (f Filter) String() string {
+	switch f.Op {
+	case FilterCond:
+		return f.Cond.String()
+	case FilterAnd, FilterOr:
+		parts := make([]string, len(f.Children))
+		for i, c := range f.Children {
+			parts[i] = c.String()
+		}
+		return "(" + strings.Join(parts, " "+string(f.Op)+" ") + ")"
+	case FilterNot:
+		return "not " + f.Children[0].String()
+	default:
+		return string(f.Op)
+	}
+}
+
+// PartialEvaluate answers "which resources may subject perform action on"
+// for a declarative policy: everything known about the subject and action is
+// evaluated, leaving a Filter over resource attributes that holds exactly for
+// the resources Evaluate would allow.
+func PartialEvaluate(spec PolicySpec, subject Subject, action Action) (Filter, error) {
+	var allows, denies []Filter
+	var allowed []Filter
+
+	for _, rule := range spec.Rules {
+		residual, err := partialRule(rule, subject, action)
+		if err != nil {
+			return Filter{}, fmt.Errorf("policy %s: %w", spec.ID, err)
+		}
+
+		if spec.Combining == CombiningAllMustAllow {
+			if rule.RuleEffect == EffectAllow {
+				allows = append(allows, residual)
+			} else {
+				denies = append(denies, residual)
+			}
+			continue
+		}
+
+		// first match: an allow rule decides unless an earlier deny matched
+		if rule.RuleEffect == EffectAllow {
+			allowed = append(allowed, andFilter(residual, notFilter(orFilter(denies...))))
+		} else {
+			denies = append(denies, residual)
+		}
+	}
+
+	if spec.Combining == CombiningAllMustAllow {
+		return andFilter(orFilter(allows...), notFilter(orFilter(denies...))), nil
+	}
+	return orFilter(allowed...), nil
+}
+
+func partialRule(rule *AttributeRule, subject Subject, action Action) (Filter, error) {
+	if len(rule.Actions) > 0 && !containsValue(rule.Actions, action.GetName()) {
+		return matchNone, nil
+	}
+
+	var parts []Filter
+	if len(rule.ResourceTypes) > 0 {
+		types := make([]interface{}, len(rule.ResourceTypes))
+		for i, t := range rule.ResourceTypes {
+			types[i] = t
+		}
+		parts = append(parts, condFilter(Condition{Attribute: "resource.type", Operator: OpIn, Value: types}))
+	}
+
+	for _, c := range rule.Conditions {
+		residual, err := partialCondition(c, subject, action)
+		if err != nil {
+			return Filter{}, fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+		parts = append(parts, residual)
+	}
+	return andFilter(parts...), nil
+}
+
+func partialCondition(c Condition, subject Subject, action Action) (Filter, error) {
+	attrOnResource := strings.HasPrefix(c.Attribute, "resource.")
+	valueOnResource := strings.HasPrefix(c.ValueFrom, "resource.")
+
+	switch {
+	case !attrOnResource && !valueOnResource:
+		if c.Matches(subject, SimpleResource{}, action) {
+			return matchAll, nil
+		}
+		return matchNone, nil
+
+	case attrOnResource && (c.ValueFrom == "" || valueOnResource):
+		return condFilter(c), nil
+
+	case attrOnResource:
+		v, ok := resolveAttribute(c.ValueFrom, subject, SimpleResource{}, action)
+		if !ok {
+			return matchNone, nil
+		}
+		return condFilter(Condition{Attribute: c.Attribute, Operator: c.Operator, Value: v}), nil
+
+	default:
+		// subject or action compared with a resource attribute: flip sides
+		v, ok := resolveAttribute(c.Attribute, subject, SimpleResource{}, action)
+		switch {
+		case c.Operator == OpEquals && !ok:
+			return matchNone, nil
+		case c.Operator == OpNotEquals && !ok:
+			return condFilter(Condition{Attribute: c.ValueFrom, Operator: OpExists}), nil
+		case c.Operator == OpEquals:
+			return condFilter(Condition{Attribute: c.ValueFrom, Operator: OpEquals, Value: v}), nil
+		case c.Operator == OpNotEquals:
+			// a missing ValueFrom never matches, unlike a missing Attribute
+			return andFilter(
+				condFilter(Condition{Attribute: c.ValueFrom, Operator: OpExists}),
+				condFilter(Condition{Attribute: c.ValueFrom, Operator: OpNotEquals, Value: v}),
+			), nil
+		default:
+			return Filter{}, fmt.Errorf("%w: %s", ErrNotPartiallyEvaluable, c)
+		}
+	}
+}
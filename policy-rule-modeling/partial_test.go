@@ -0,0 +1,73 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPartialEvaluate(t *testing.T) {
+	ctx := context.Background()
+	bundle, err := LoadBundle("testdata/bundle.json")
+	if err != nil {
+		t.Fatalf("Expected bundle to load, got %v", err)
+	}
+	spec := bundle.Policies[0]
+	policy := spec.Build()
+
+	resources := []SimpleResource{
+		{Type: "document", ID: "doc1", Attributes: map[string]interface{}{"owner": "user456"}},
+		{Type: "document", ID: "doc2", Attributes: map[string]interface{}{"owner": "user456", "classification": "confidential"}},
+		{Type: "document", ID: "doc3", Attributes: map[string]interface{}{"owner": "admin123"}},
+		{Type: "document", ID: "doc4", Attributes: map[string]interface{}{}},
+		{Type: "folder", ID: "folder1", Attributes: map[string]interface{}{"owner": "user456"}},
+	}
+	subjects := []SimpleSubject{
+		{ID: "admin123", Attributes: map[string]interface{}{"role": "admin"}},
+		{ID: "user456", Attributes: map[string]interface{}{"role": "viewer"}},
+		{ID: "user789", Attributes: map[string]interface{}{}},
+	}
+
+	for _, subject := range subjects {
+		for _, action := range []string{"read", "delete"} {
+			a := SimpleAction{Name: action}
+			filter, err := PartialEvaluate(spec, subject, a)
+			if err != nil {
+				t.Fatalf("Expected partial evaluation to succeed, got %v", err)
+			}
+
+			for _, resource := range resources {
+				want := policy.Evaluate(ctx, subject, resource, a).Allow
+				if got := filter.Matches(resource); got != want {
+					t.Errorf("%s %s %s: filter %s matched=%v, Evaluate allow=%v", subject.ID, action, resource.ID, filter, got, want)
+				}
+			}
+		}
+	}
+
+	t.Run("subject without access matches nothing", func(t *testing.T) {
+		filter, _ := PartialEvaluate(PolicySpec{ID: "p", Rules: []*AttributeRule{
+			{ID: "admin-all", RuleEffect: EffectAllow, Conditions: []Condition{{Attribute: "subject.role", Operator: OpEquals, Value: "admin"}}},
+		}}, subjects[1], SimpleAction{Name: "read"})
+		if !filter.MatchesNothing() {
+			t.Errorf("Expected empty filter, got %s", filter)
+		}
+	})
+
+	t.Run("residual mentions only resource attributes", func(t *testing.T) {
+		filter, _ := PartialEvaluate(spec, subjects[1], SimpleAction{Name: "delete"})
+		want := "(resource.type in [document] and resource.owner eq user456 and not (resource.type in [document] and resource.classification eq confidential))"
+		if filter.String() != want {
+			t.Errorf("Expected %s, got %s", want, filter)
+		}
+	})
+
+	t.Run("rejects subject membership in resource list", func(t *testing.T) {
+		_, err := PartialEvaluate(PolicySpec{ID: "p", Rules: []*AttributeRule{
+			{ID: "shared", RuleEffect: EffectAllow, Conditions: []Condition{{Attribute: "subject.id", Operator: OpIn, ValueFrom: "resource.shared_with"}}},
+		}}, subjects[1], SimpleAction{Name: "read"})
+		if !errors.Is(err, ErrNotPartiallyEvaluable) {
+			t.Errorf("Expected ErrNotPartiallyEvaluable, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package pdp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+)
+
+var _ prm.Policy = (*Client)(nil)
+
+// Client evaluates one policy on a remote PDP. It implements prm.Policy, so it
+// can replace a locally built policy. The prm.Environment in the context
+// travels with each request. Transport failures fail closed.
+type Client struct {
+	policyID string
+	tenant   string
+	evaluate endpoint.Endpoint
+	batch    endpoint.Endpoint
+}
+
+type ClientOption func(*Client)
+
+// WithTenant sends tenant in the X-Tenant-ID header, which a PDP serving
+// NewTenantHTTPHandler needs to find the policy. It is the fallback for
+// requests whose environment names no tenant.
+func WithTenant(tenant string) ClientOption {
+	return func(c *Client) { c.tenant = tenant }
+}
+
+func NewClient(baseURL, policyID string, opts ...ClientOption) (*Client, error) {
+	if !strings.HasPrefix(baseURL, "http") {
+		baseURL = "http://" + baseURL
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{policyID: policyID}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	options := []httptransport.ClientOption{
+		httptransport.ClientBefore(c.setTenant),
+	}
+	c.evaluate = httptransport.NewClient(
+		"POST",
+		u.JoinPath("/v1/evaluate"),
+		encodeRequest,
+		decodeEvaluateResponse,
+		options...,
+	).Endpoint()
+	c.batch = httptransport.NewClient(
+		"POST",
+		u.JoinPath("/v1/evaluate/batch"),
+		encodeRequest,
+		decodeBatchResponse,
+		options...,
+	).Endpoint()
+	return c, nil
+}
+
+// environment is the prm.Environment in ctx, with the client's tenant when it
+// names none.
+func (c *Client) environment(ctx context.Context) Environment {
+	env := NewEnvironment(prm.EnvironmentFromContext(ctx))
+	if env.Tenant == "" {
+		env.Tenant = c.tenant
+	}
+	return env
+}
+
+func (c *Client) setTenant(ctx context.Context, r *http.Request) context.Context {
+	if tenant := c.environment(ctx).Tenant; tenant != "" {
+		r.Header.Set("X-Tenant-ID", tenant)
+	}
+	return ctx
+}
+
+func (c *Client) Evaluate(ctx context.Context, subject prm.Subject, resource prm.Resource, action prm.Action) prm.Decision {
+	response, err := c.evaluate(ctx, evaluateRequest{c.policyID, c.environment(ctx), NewItem(subject, resource, action)})
+	if err != nil {
+		return prm.Decision{Allow: false, Reason: "pdp unavailable: " + err.Error()}
+	}
+
+	resp := response.(evaluateResponse)
+	if resp.Err != "" {
+		return prm.Decision{Allow: false, Reason: resp.Err}
+	}
+
+	return resp.Decision
+}
+
+// EvaluateBatch returns one decision per item, in order.
+func (c *Client) EvaluateBatch(ctx context.Context, items []Item) ([]prm.Decision, error) {
+	response, err := c.batch(ctx, batchRequest{c.policyID, c.environment(ctx), items})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := response.(batchResponse)
+	if resp.Err != "" {
+		return nil, errors.New(resp.Err)
+	}
+	if len(resp.Decisions) != len(items) {
+		return nil, fmt.Errorf("pdp returned %d decisions for %d items", len(resp.Decisions), len(items))
+	}
+
+	return resp.Decisions, nil
+}
+
+func (c *Client) GetID() string {
+	return c.policyID
+}
+
+func (c *Client) GetName() string {
+	return "remote:" + c.policyID
+}
@@ -0,0 +1,117 @@
+package pdp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	bundle, err := prm.LoadBundle("../testdata/bundle.json")
+	if err != nil {
+		t.Fatalf("Expected bundle to load, got %v", err)
+	}
+
+	reg := NewRegistry()
+	reg.Load(bundle)
+	srv := httptest.NewServer(NewHTTPHandler(reg))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_Evaluate(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t)
+	viewer := prm.SimpleSubject{ID: "user456", Attributes: map[string]interface{}{"role": "viewer"}}
+	doc := prm.SimpleResource{Type: "document", ID: "doc1", Attributes: map[string]interface{}{"owner": "admin123"}}
+
+	t.Run("matches local evaluation", func(t *testing.T) {
+		remote, err := NewClient(srv.URL, "documents")
+		if err != nil {
+			t.Fatalf("Expected client, got %v", err)
+		}
+		bundle, _ := prm.LoadBundle("../testdata/bundle.json")
+		local := bundle.Build()["documents"]
+
+		for _, action := range []string{"read", "delete"} {
+			a := prm.SimpleAction{Name: action}
+			want := local.Evaluate(ctx, viewer, doc, a)
+			got := remote.Evaluate(ctx, viewer, doc, a)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected remote decision %+v to equal local %+v for %s", got, want, action)
+			}
+		}
+	})
+
+	t.Run("denies unknown policy", func(t *testing.T) {
+		client, _ := NewClient(srv.URL, "missing")
+		decision := client.Evaluate(ctx, viewer, doc, prm.SimpleAction{Name: "read"})
+		if decision.Allow || decision.Reason != ErrUnknownPolicy.Error() {
+			t.Errorf("Expected deny for unknown policy, got %+v", decision)
+		}
+	})
+
+	t.Run("fails closed when unreachable", func(t *testing.T) {
+		client, _ := NewClient("127.0.0.1:1", "documents")
+		if decision := client.Evaluate(ctx, viewer, doc, prm.SimpleAction{Name: "read"}); decision.Allow {
+			t.Errorf("Expected deny when pdp is unreachable, got %+v", decision)
+		}
+	})
+}
+
+func TestClient_EvaluateBatch(t *testing.T) {
+	srv := newTestServer(t)
+	client, _ := NewClient(srv.URL, "documents")
+	viewer := prm.SimpleSubject{ID: "user456", Attributes: map[string]interface{}{"role": "viewer"}}
+	doc := prm.SimpleResource{Type: "document", ID: "doc1", Attributes: map[string]interface{}{"owner": "admin123"}}
+
+	items := []Item{
+		NewItem(viewer, doc, prm.SimpleAction{Name: "read"}),
+		NewItem(viewer, doc, prm.SimpleAction{Name: "delete"}),
+	}
+	decisions, err := client.EvaluateBatch(context.Background(), items)
+	if err != nil {
+		t.Fatalf("Expected batch to succeed, got %v", err)
+	}
+	if len(decisions) != 2 || !decisions[0].Allow || decisions[1].Allow {
+		t.Errorf("Expected [allow, deny], got %+v", decisions)
+	}
+
+	_, err = client.EvaluateBatch(context.Background(), make([]Item, MaxBatchSize+1))
+	if err == nil || err.Error() != ErrBatchTooLarge.Error() {
+		t.Errorf("Expected ErrBatchTooLarge, got %v", err)
+	}
+}
+
+func TestHTTPHandler_RejectsBadRequests(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"malformed json", `{"policy_id":`, http.StatusBadRequest},
+		{"oversized body", `{"policy_id":"` + strings.Repeat("x", MaxRequestBytes) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+"/v1/evaluate", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Expected response, got %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, resp.StatusCode)
+			}
+		})
+	}
+}
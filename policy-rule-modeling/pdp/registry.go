@@ -0,0 +1,63 @@
+package pdp
+
+import (
+	"sort"
+	"sync"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+)
+
+// Registry holds the policies currently served. Load swaps them atomically so
+// a bundle reload never exposes a half-updated set.
+type Registry struct {
+	decorators []func(prm.Policy) prm.Policy
+
+	mu       sync.RWMutex
+	revision string
+	policies map[string]prm.Policy
+}
+
+// NewRegistry wraps every loaded policy in decorators, innermost first,
+// e.g. for instrumentation.
+func NewRegistry(decorators ...func(prm.Policy) prm.Policy) *Registry {
+	return &Registry{decorators: decorators, policies: map[string]prm.Policy{}}
+}
+
+func (r *Registry) Load(bundle *prm.Bundle) {
+	policies := bundle.Build()
+	for id, p := range policies {
+		for _, decorate := range r.decorators {
+			p = decorate(p)
+		}
+		policies[id] = p
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revision = bundle.Revision
+	r.policies = policies
+}
+
+func (r *Registry) Get(id string) (prm.Policy, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.policies[id]
+	return p, ok
+}
+
+func (r *Registry) Revision() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.revision
+}
+
+func (r *Registry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.policies))
+	for id := range r.policies {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,165 @@
+package pdp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+)
+
+func tenantLookup(reg *prm.TenantRegistry) lookupFunc {
+	return func(ctx context.Context, policyID string) (prm.Policy, error) {
+		if _, err := reg.Get(ctx, policyID); err != nil {
+			return nil, err
+		}
+		return reg.Policy(policyID), nil
+	}
+}
+
+// NewTenantHTTPHandler serves the same evaluation API as NewHTTPHandler, with
+// policies looked up in the tenant named by the request's environment or, when
+// it names none, the X-Tenant-ID header. Requests without a tenant are denied.
+func NewTenantHTTPHandler(reg *prm.TenantRegistry, clock prm.Clock) http.Handler {
+	options := []httptransport.ServerOption{
+		httptransport.ServerBefore(prm.EnvironmentToContext(clock)),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /v1/evaluate", httptransport.NewServer(
+		makeEvaluateEndpoint(tenantLookup(reg)),
+		decodeEvaluateRequest,
+		encodeResponse,
+		options...,
+	))
+	mux.Handle("POST /v1/evaluate/batch", httptransport.NewServer(
+		makeBatchEndpoint(tenantLookup(reg)),
+		decodeBatchRequest,
+		encodeResponse,
+		options...,
+	))
+	return mux
+}
+
+type tenantPoliciesRequest struct {
+	Tenant string
+}
+
+type tenantsResponse struct {
+	Tenants []string `json:"tenants"`
+}
+
+type tenantPoliciesResponse struct {
+	Tenant string   `json:"tenant"`
+	IDs    []string `json:"ids"`
+}
+
+type putPolicyRequest struct {
+	Tenant string
+	Spec   prm.PolicySpec
+}
+
+type deletePolicyRequest struct {
+	Tenant   string
+	PolicyID string
+}
+
+type adminResponse struct {
+	Err string `json:"err,omitempty"`
+}
+
+func makeTenantsEndpoint(reg *prm.TenantRegistry) endpoint.Endpoint {
+	return func(_ context.Context, _ interface{}) (interface{}, error) {
+		return tenantsResponse{reg.Tenants()}, nil
+	}
+}
+
+func makeTenantPoliciesEndpoint(reg *prm.TenantRegistry) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		req := request.(tenantPoliciesRequest)
+		return tenantPoliciesResponse{req.Tenant, reg.IDs(req.Tenant)}, nil
+	}
+}
+
+func makePutPolicyEndpoint(reg *prm.TenantRegistry) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		req := request.(putPolicyRequest)
+		if err := reg.Add(req.Tenant, req.Spec.Build()); err != nil {
+			return adminResponse{err.Error()}, nil
+		}
+		return adminResponse{}, nil
+	}
+}
+
+func makeDeletePolicyEndpoint(reg *prm.TenantRegistry) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		req := request.(deletePolicyRequest)
+		if err := reg.Remove(req.Tenant, req.PolicyID); err != nil {
+			return adminResponse{err.Error()}, nil
+		}
+		return adminResponse{}, nil
+	}
+}
+
+// NewAdminHandler manages the policies of each tenant. It performs no
+// authentication of its own and belongs on an internal listener.
+//
+//	GET    /v1/admin/tenants                          tenants with policies
+//	GET    /v1/admin/tenants/{tenant}/policies        a tenant's policy IDs
+//	PUT    /v1/admin/tenants/{tenant}/policies/{id}   add or replace a PolicySpec
+//	DELETE /v1/admin/tenants/{tenant}/policies/{id}   remove a policy
+func NewAdminHandler(reg *prm.TenantRegistry) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /v1/admin/tenants", httptransport.NewServer(
+		makeTenantsEndpoint(reg),
+		httptransport.NopRequestDecoder,
+		encodeResponse,
+	))
+	mux.Handle("GET /v1/admin/tenants/{tenant}/policies", httptransport.NewServer(
+		makeTenantPoliciesEndpoint(reg),
+		decodeTenantPoliciesRequest,
+		encodeResponse,
+	))
+	mux.Handle("PUT /v1/admin/tenants/{tenant}/policies/{id}", httptransport.NewServer(
+		makePutPolicyEndpoint(reg),
+		decodePutPolicyRequest,
+		encodeResponse,
+	))
+	mux.Handle("DELETE /v1/admin/tenants/{tenant}/policies/{id}", httptransport.NewServer(
+		makeDeletePolicyEndpoint(reg),
+		decodeDeletePolicyRequest,
+		encodeResponse,
+	))
+	return mux
+}
+
+func decodeTenantPoliciesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return tenantPoliciesRequest{Tenant: r.PathValue("tenant")}, nil
+}
+
+func decodePutPolicyRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request putPolicyRequest
+	if err := decodeBody(r, &request.Spec); err != nil {
+		return nil, err
+	}
+
+	id := r.PathValue("id")
+	if request.Spec.ID == "" {
+		request.Spec.ID = id
+	}
+	if request.Spec.ID != id {
+		return nil, badRequestError{fmt.Errorf("policy id %q does not match path %q", request.Spec.ID, id)}
+	}
+	if err := request.Spec.Validate(); err != nil {
+		return nil, badRequestError{err}
+	}
+	request.Tenant = r.PathValue("tenant")
+	return request, nil
+}
+
+func decodeDeletePolicyRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return deletePolicyRequest{Tenant: r.PathValue("tenant"), PolicyID: r.PathValue("id")}, nil
+}
@@ -0,0 +1,176 @@
+package pdp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+)
+
+func TestTenantHandlers(t *testing.T) {
+	reg := prm.NewTenantRegistry()
+	admin := httptest.NewServer(NewAdminHandler(reg))
+	defer admin.Close()
+	evaluate := httptest.NewServer(NewTenantHTTPHandler(reg, prm.RealClock{}))
+	defer evaluate.Close()
+
+	do := func(method, url, tenant string, body interface{}, out interface{}) {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req, _ := http.NewRequest(method, url, &buf)
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected %s %s to succeed, got %v", method, url, err)
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("Expected JSON from %s %s, got %v", method, url, err)
+		}
+	}
+
+	spec := prm.PolicySpec{Name: "Allow Read", Combining: prm.CombiningFirstMatch, Rules: []*prm.AttributeRule{
+		{ID: "read", RuleEffect: prm.EffectAllow, Actions: []string{"read"}},
+	}}
+	var added adminResponse
+	do("PUT", admin.URL+"/v1/admin/tenants/acme/policies/documents", "", spec, &added)
+	if added.Err != "" {
+		t.Fatalf("Expected policy to be added, got %s", added.Err)
+	}
+
+	var listed tenantPoliciesResponse
+	do("GET", admin.URL+"/v1/admin/tenants/acme/policies", "", nil, &listed)
+	if !reflect.DeepEqual(listed.IDs, []string{"documents"}) {
+		t.Errorf("Expected acme to have [documents], got %v", listed.IDs)
+	}
+
+	alice := prm.SimpleSubject{ID: "alice"}
+	doc := prm.SimpleResource{Type: "document", ID: "doc1", Attributes: map[string]interface{}{"tenant": "acme"}}
+	request := evaluateRequest{PolicyID: "documents", Item: NewItem(alice, doc, prm.SimpleAction{Name: "read"})}
+
+	var got evaluateResponse
+	do("POST", evaluate.URL+"/v1/evaluate", "acme", request, &got)
+	if !got.Decision.Allow {
+		t.Errorf("Expected acme to be allowed, got %+v", got)
+	}
+
+	got = evaluateResponse{}
+	do("POST", evaluate.URL+"/v1/evaluate", "globex", request, &got)
+	if got.Decision.Allow || got.Err == "" {
+		t.Errorf("Expected globex to be denied acme's policy, got %+v", got)
+	}
+
+	acme, _ := NewClient(evaluate.URL, "documents", WithTenant("acme"))
+	if decision := acme.Evaluate(context.Background(), alice, doc, prm.SimpleAction{Name: "read"}); !decision.Allow {
+		t.Errorf("Expected client for acme to be allowed, got %+v", decision)
+	}
+	untenanted, _ := NewClient(evaluate.URL, "documents")
+	if decision := untenanted.Evaluate(context.Background(), alice, doc, prm.SimpleAction{Name: "read"}); decision.Allow {
+		t.Errorf("Expected client without tenant to be denied, got %+v", decision)
+	}
+
+	var removed adminResponse
+	do("DELETE", admin.URL+"/v1/admin/tenants/acme/policies/documents", "", nil, &removed)
+	if removed.Err != "" {
+		t.Fatalf("Expected policy to be removed, got %s", removed.Err)
+	}
+	got = evaluateResponse{}
+	do("POST", evaluate.URL+"/v1/evaluate", "acme", request, &got)
+	if got.Decision.Allow {
+		t.Errorf("Expected removed policy to deny, got %+v", got)
+	}
+}
+
+func TestAdminHandler_RejectsInvalidSpecs(t *testing.T) {
+	admin := httptest.NewServer(NewAdminHandler(prm.NewTenantRegistry()))
+	defer admin.Close()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"null rule", `{"rules":[null]}`},
+		{"unknown operator", `{"rules":[{"id":"r","conditions":[{"attribute":"subject.role","operator":"like","value":"x"}]}]}`},
+		{"mismatched id", `{"id":"other","rules":[]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("PUT", admin.URL+"/v1/admin/tenants/acme/policies/documents", strings.NewReader(tt.body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Expected response, got %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestClient_SendsEnvironment(t *testing.T) {
+	reg := prm.NewTenantRegistry()
+	office := &prm.SimplePolicy{ID: "office", Name: "Office Hours", Rules: []prm.Rule{
+		&prm.AllOfRule{ID: "office", RuleEffect: prm.EffectAllow, Rules: []prm.Rule{
+			&prm.IPRule{ID: "office-network", Prefixes: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+			&prm.BusinessHoursRule{ID: "weekdays", Weekdays: []time.Weekday{time.Monday}, Start: 9 * time.Hour, End: 17 * time.Hour},
+		}},
+	}}
+	if err := reg.Add("acme", office); err != nil {
+		t.Fatalf("Expected policy to be added, got %v", err)
+	}
+	srv := httptest.NewServer(NewTenantHTTPHandler(reg, prm.RealClock{}))
+	defer srv.Close()
+
+	client, _ := NewClient(srv.URL, "office", WithTenant("globex"))
+	alice := prm.SimpleSubject{ID: "alice"}
+	doc := prm.SimpleResource{Type: "document", ID: "doc1"}
+	monday := time.Date(2024, time.January, 8, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		env  prm.Environment
+		want bool
+	}{
+		{"caller environment decides", prm.Environment{Clock: fakeClock{monday}, ClientIP: netip.MustParseAddr("10.1.2.3"), Tenant: "acme"}, true},
+		{"outside the office network", prm.Environment{Clock: fakeClock{monday}, ClientIP: netip.MustParseAddr("192.0.2.1"), Tenant: "acme"}, false},
+		{"outside business hours", prm.Environment{Clock: fakeClock{monday.Add(10 * time.Hour)}, ClientIP: netip.MustParseAddr("10.1.2.3"), Tenant: "acme"}, false},
+		{"falls back to the client tenant", prm.Environment{Clock: fakeClock{monday}, ClientIP: netip.MustParseAddr("10.1.2.3")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := prm.ContextWithEnvironment(context.Background(), tt.env)
+			if got := client.Evaluate(ctx, alice, doc, prm.SimpleAction{Name: "read"}); got.Allow != tt.want {
+				t.Errorf("Expected allow %v, got %+v", tt.want, got)
+			}
+			decisions, err := client.EvaluateBatch(ctx, []Item{NewItem(alice, doc, prm.SimpleAction{Name: "read"})})
+			if allowed := err == nil && decisions[0].Allow; allowed != tt.want {
+				t.Errorf("Expected batch allow %v, got %+v, %v", tt.want, decisions, err)
+			}
+		})
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c fakeClock) Now() time.Time {
+	return c.now
+}
@@ -0,0 +1,265 @@
+package pdp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+)
+
+const MaxBatchSize = 1000
+
+// MaxRequestBytes bounds request bodies; a full batch with attributes fits.
+const MaxRequestBytes = 4 << 20
+
+var (
+	ErrUnknownPolicy = errors.New("unknown policy")
+	ErrBatchTooLarge = fmt.Errorf("batch exceeds %d items", MaxBatchSize)
+)
+
+// Item is one (subject, resource, action) tuple on the wire.
+type Item struct {
+	Subject  prm.SimpleSubject  `json:"subject"`
+	Resource prm.SimpleResource `json:"resource"`
+	Action   prm.SimpleAction   `json:"action"`
+}
+
+func NewItem(subject prm.Subject, resource prm.Resource, action prm.Action) Item {
+	return Item{
+		Subject:  prm.SimpleSubject{ID: subject.GetID(), Attributes: subject.GetAttributes()},
+		Resource: prm.SimpleResource{Type: resource.GetType(), ID: resource.GetID(), Attributes: resource.GetAttributes()},
+		Action:   prm.SimpleAction{Name: action.GetName()},
+	}
+}
+
+// Environment is the caller's prm.Environment on the wire. The PDP evaluates
+// with it rather than with what its own HTTP request says, which names the
+// enforcement point instead of the end user.
+type Environment struct {
+	ClientIP  netip.Addr `json:"client_ip,omitzero"`
+	RequestID string     `json:"request_id,omitempty"`
+	Tenant    string     `json:"tenant,omitempty"`
+	Time      time.Time  `json:"time,omitzero"`
+}
+
+func NewEnvironment(env prm.Environment) Environment {
+	return Environment{
+		ClientIP:  env.ClientIP,
+		RequestID: env.RequestID,
+		Tenant:    env.Tenant,
+		Time:      env.Now(),
+	}
+}
+
+// toContext overrides the environment in ctx with the fields that are set.
+// A time freezes the clock at that instant.
+func (e Environment) toContext(ctx context.Context) context.Context {
+	env := prm.EnvironmentFromContext(ctx)
+	if e.ClientIP.IsValid() {
+		env.ClientIP = e.ClientIP
+	}
+	if e.RequestID != "" {
+		env.RequestID = e.RequestID
+	}
+	if e.Tenant != "" {
+		env.Tenant = e.Tenant
+	}
+	if !e.Time.IsZero() {
+		env.Clock = fixedClock(e.Time)
+	}
+	return prm.ContextWithEnvironment(ctx, env)
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+type evaluateRequest struct {
+	PolicyID    string      `json:"policy_id"`
+	Environment Environment `json:"environment,omitzero"`
+	Item
+}
+
+type evaluateResponse struct {
+	Decision prm.Decision `json:"decision"`
+	Err      string       `json:"err,omitempty"`
+}
+
+type batchRequest struct {
+	PolicyID    string      `json:"policy_id"`
+	Environment Environment `json:"environment,omitzero"`
+	Items       []Item      `json:"items"`
+}
+
+type batchResponse struct {
+	Decisions []prm.Decision `json:"decisions"`
+	Err       string         `json:"err,omitempty"`
+}
+
+// badRequestError is answered with 400 by go-kit's DefaultErrorEncoder, or
+// with 413 when the body exceeded MaxRequestBytes.
+type badRequestError struct {
+	err error
+}
+
+func (e badRequestError) Error() string { return "bad request: " + e.err.Error() }
+
+func (e badRequestError) StatusCode() int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(e.err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// decodeBody decodes a JSON request body of at most MaxRequestBytes into v.
+func decodeBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, MaxRequestBytes)).Decode(v); err != nil {
+		return badRequestError{err}
+	}
+	return nil
+}
+
+type policiesResponse struct {
+	Revision string   `json:"revision"`
+	IDs      []string `json:"ids"`
+}
+
+// lookupFunc finds the policy a request names, possibly depending on the
+// tenant in ctx.
+type lookupFunc func(ctx context.Context, policyID string) (prm.Policy, error)
+
+func (r *Registry) lookup(_ context.Context, policyID string) (prm.Policy, error) {
+	policy, ok := r.Get(policyID)
+	if !ok {
+		return nil, ErrUnknownPolicy
+	}
+	return policy, nil
+}
+
+func makeEvaluateEndpoint(lookup lookupFunc) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(evaluateRequest)
+		ctx = req.Environment.toContext(ctx)
+		policy, err := lookup(ctx, req.PolicyID)
+		if err != nil {
+			return evaluateResponse{prm.Decision{Reason: err.Error()}, err.Error()}, nil
+		}
+
+		return evaluateResponse{policy.Evaluate(ctx, req.Subject, req.Resource, req.Action), ""}, nil
+	}
+}
+
+func makeBatchEndpoint(lookup lookupFunc) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(batchRequest)
+		if len(req.Items) > MaxBatchSize {
+			return batchResponse{nil, ErrBatchTooLarge.Error()}, nil
+		}
+
+		ctx = req.Environment.toContext(ctx)
+		policy, err := lookup(ctx, req.PolicyID)
+		if err != nil {
+			return batchResponse{nil, err.Error()}, nil
+		}
+
+		decisions := make([]prm.Decision, len(req.Items))
+		for i, item := range req.Items {
+			decisions[i] = policy.Evaluate(ctx, item.Subject, item.Resource, item.Action)
+		}
+		return batchResponse{decisions, ""}, nil
+	}
+}
+
+func makePoliciesEndpoint(reg *Registry) endpoint.Endpoint {
+	return func(_ context.Context, _ interface{}) (interface{}, error) {
+		return policiesResponse{reg.Revision(), reg.IDs()}, nil
+	}
+}
+
+// NewHTTPHandler serves the registry's policies:
+//
+//	POST /v1/evaluate        one tuple
+//	POST /v1/evaluate/batch  up to MaxBatchSize tuples against one policy
+//	GET  /v1/policies        loaded revision and policy IDs
+func NewHTTPHandler(reg *Registry) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /v1/evaluate", httptransport.NewServer(
+		makeEvaluateEndpoint(reg.lookup),
+		decodeEvaluateRequest,
+		encodeResponse,
+	))
+	mux.Handle("POST /v1/evaluate/batch", httptransport.NewServer(
+		makeBatchEndpoint(reg.lookup),
+		decodeBatchRequest,
+		encodeResponse,
+	))
+	mux.Handle("GET /v1/policies", httptransport.NewServer(
+		makePoliciesEndpoint(reg),
+		httptransport.NopRequestDecoder,
+		encodeResponse,
+	))
+	return mux
+}
+
+func decodeEvaluateRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request evaluateRequest
+	if err := decodeBody(r, &request); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func decodeBatchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request batchRequest
+	if err := decodeBody(r, &request); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func encodeResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func encodeRequest(_ context.Context, r *http.Request, request interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(request); err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(&buf)
+	return nil
+}
+
+func decodeEvaluateResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var response evaluateResponse
+	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func decodeBatchResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var response batchResponse
+	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
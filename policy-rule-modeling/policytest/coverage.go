@@ -0,0 +1,110 @@
+package policytest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+)
+
+// Coverage counts how often each rule of a policy matched.
+type Coverage struct {
+	mu      sync.Mutex
+	ruleIDs []string
+	hits    map[string]int
+}
+
+func (c *Coverage) record(ruleID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hits[ruleID]++
+}
+
+func (c *Coverage) Hits(ruleID string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits[ruleID]
+}
+
+// Unexercised returns the IDs of rules that never matched, in rule order.
+func (c *Coverage) Unexercised() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ids []string
+	for _, id := range c.ruleIDs {
+		if c.hits[id] == 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (c *Coverage) Report() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var b strings.Builder
+	covered := 0
+	for _, id := range c.ruleIDs {
+		if c.hits[id] > 0 {
+			covered++
+		}
+		fmt.Fprintf(&b, "  %-30s %d\n", id, c.hits[id])
+	}
+	return fmt.Sprintf("rule coverage: %d/%d rules matched\n%s", covered, len(c.ruleIDs), b.String())
+}
+
+type recordingRule struct {
+	prm.Rule
+	coverage *Coverage
+}
+
+func (r recordingRule) Matches(ctx context.Context, subject prm.Subject, resource prm.Resource, action prm.Action) bool {
+	matched := r.Rule.Matches(ctx, subject, resource, action)
+	if matched {
+		r.coverage.record(r.GetID())
+	}
+	return matched
+}
+
+func (r recordingRule) GetObligations() []prm.Obligation {
+	if o, ok := r.Rule.(prm.ObligationRule); ok {
+		return o.GetObligations()
+	}
+	return nil
+}
+
+func (r recordingRule) GetAdvice() []prm.Obligation {
+	if o, ok := r.Rule.(prm.ObligationRule); ok {
+		return o.GetAdvice()
+	}
+	return nil
+}
+
+// Instrument returns a copy of policy whose rules record matches. Only
+// SimplePolicy and AllMustAllowPolicy expose their rules; other policies are
+// returned unchanged with a nil Coverage.
+func Instrument(policy prm.Policy) (prm.Policy, *Coverage) {
+	switch p := policy.(type) {
+	case *prm.SimplePolicy:
+		rules, coverage := instrumentRules(p.Rules)
+		return &prm.SimplePolicy{ID: p.ID, Name: p.Name, Rules: rules}, coverage
+	case *prm.AllMustAllowPolicy:
+		rules, coverage := instrumentRules(p.Rules)
+		return &prm.AllMustAllowPolicy{ID: p.ID, Name: p.Name, Rules: rules}, coverage
+	default:
+		return policy, nil
+	}
+}
+
+func instrumentRules(rules []prm.Rule) ([]prm.Rule, *Coverage) {
+	coverage := &Coverage{hits: map[string]int{}}
+	wrapped := make([]prm.Rule, len(rules))
+	for i, rule := range rules {
+		coverage.ruleIDs = append(coverage.ruleIDs, rule.GetID())
+		wrapped[i] = recordingRule{rule, coverage}
+	}
+	return wrapped, coverage
+}
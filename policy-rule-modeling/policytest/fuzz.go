@@ -0,0 +1,140 @@
+package policytest
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"testing"
+
+	"pgregory.net/rapid"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+)
+
+// Fuzz checks every case with Invariant paths: it redraws those attributes
+// from the values seen anywhere in the table, plus arbitrary strings and
+// absence, and fails with a shrunk counterexample when the decision flips.
+func Fuzz(t *testing.T, policy prm.Policy, table *Table) {
+	t.Helper()
+	domains := collectDomains(table)
+
+	for _, c := range table.Cases {
+		if len(c.Invariant) == 0 {
+			continue
+		}
+		t.Run(c.Name, func(t *testing.T) {
+			rapid.Check(t, func(rt *rapid.T) {
+				mutated := c
+				mutated.Subject.Attributes = maps.Clone(c.Subject.Attributes)
+				mutated.Resource.Attributes = maps.Clone(c.Resource.Attributes)
+
+				for _, path := range c.Invariant {
+					value, present := drawValue(rt, path, domains[path])
+					if err := setAttribute(&mutated, path, value, present); err != nil {
+						rt.Fatal(err)
+					}
+				}
+
+				decision := mutated.evaluate(context.Background(), policy)
+				if decision.Allow != c.Allow {
+					rt.Fatalf("decision flipped to allow=%v (reason %q) for subject %v, resource %v",
+						decision.Allow, decision.Reason, mutated.Subject, mutated.Resource)
+				}
+			})
+		})
+	}
+}
+
+func drawValue(t *rapid.T, path string, domain []interface{}) (interface{}, bool) {
+	choices := []*rapid.Generator[interface{}]{rapid.Map(rapid.String(), func(s string) interface{} { return s })}
+	if len(domain) > 0 {
+		choices = append(choices, rapid.SampledFrom(domain))
+	}
+
+	if !isIdentity(path) && rapid.Bool().Draw(t, path+" absent") {
+		return nil, false
+	}
+	return rapid.OneOf(choices...).Draw(t, path), true
+}
+
+func isIdentity(path string) bool {
+	return path == "subject.id" || path == "resource.id" || path == "resource.type" || path == "action"
+}
+
+// collectDomains gathers, per attribute path, every value used in the table.
+func collectDomains(table *Table) map[string][]interface{} {
+	seen := map[string]map[string]interface{}{}
+	add := func(path string, v interface{}) {
+		if seen[path] == nil {
+			seen[path] = map[string]interface{}{}
+		}
+		seen[path][fmt.Sprintf("%T:%v", v, v)] = v
+	}
+
+	for _, c := range table.Cases {
+		add("subject.id", c.Subject.ID)
+		add("resource.id", c.Resource.ID)
+		add("resource.type", c.Resource.Type)
+		add("action", c.Action)
+		for k, v := range c.Subject.Attributes {
+			add("subject."+k, v)
+		}
+		for k, v := range c.Resource.Attributes {
+			add("resource."+k, v)
+		}
+	}
+
+	domains := map[string][]interface{}{}
+	for path, values := range seen {
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		// stable order keeps rapid's failure files replayable
+		sort.Strings(keys)
+		for _, k := range keys {
+			domains[path] = append(domains[path], values[k])
+		}
+	}
+	return domains
+}
+
+func setAttribute(c *Case, path string, value interface{}, present bool) error {
+	switch path {
+	case "subject.id":
+		c.Subject.ID = fmt.Sprint(value)
+		return nil
+	case "resource.id":
+		c.Resource.ID = fmt.Sprint(value)
+		return nil
+	case "resource.type":
+		c.Resource.Type = fmt.Sprint(value)
+		return nil
+	case "action":
+		c.Action = fmt.Sprint(value)
+		return nil
+	}
+
+	scope, name, _ := strings.Cut(path, ".")
+	var attrs *map[string]interface{}
+	switch scope {
+	case "subject":
+		attrs = &c.Subject.Attributes
+	case "resource":
+		attrs = &c.Resource.Attributes
+	default:
+		return fmt.Errorf("invalid invariant path %q", path)
+	}
+
+	if *attrs == nil {
+		*attrs = map[string]interface{}{}
+	}
+	if present {
+		(*attrs)[name] = value
+	} else {
+		delete(*attrs, name)
+	}
+	return nil
+}
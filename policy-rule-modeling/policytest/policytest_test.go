@@ -0,0 +1,38 @@
+package policytest
+
+import (
+	"testing"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+)
+
+func loadDocuments(t *testing.T) (prm.Policy, *Table) {
+	t.Helper()
+	bundle, err := prm.LoadBundle("../testdata/bundle.json")
+	if err != nil {
+		t.Fatalf("Expected bundle to load, got %v", err)
+	}
+	table, err := LoadTable("../testdata/documents_decisions.yaml")
+	if err != nil {
+		t.Fatalf("Expected decision table to load, got %v", err)
+	}
+	return bundle.Build()[table.Policy], table
+}
+
+func TestDocumentsDecisionTable(t *testing.T) {
+	policy, table := loadDocuments(t)
+
+	coverage := Run(t, policy, table)
+
+	if coverage == nil {
+		t.Fatalf("Expected coverage for bundle policy")
+	}
+	if got := coverage.Unexercised(); len(got) != 0 {
+		t.Errorf("Expected every rule to be exercised, got %v unexercised", got)
+	}
+}
+
+func TestDocumentsFuzz(t *testing.T) {
+	policy, table := loadDocuments(t)
+	Fuzz(t, policy, table)
+}
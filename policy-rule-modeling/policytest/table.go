@@ -0,0 +1,90 @@
+// Package policytest runs decision tables against any Policy from go test,
+// reports which rules the table exercised and fuzzes attributes a case
+// claims not to depend on.
+package policytest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	prm "github.com/shiiyan/learn-go/policy-rule-modeling"
+)
+
+// Case is one row of a decision table. Reason and MatchedBy are only checked
+// when set. Invariant lists attribute paths (see prm.Condition) the decision
+// must not depend on; Fuzz varies them.
+type Case struct {
+	Name      string             `yaml:"name"`
+	Subject   prm.SimpleSubject  `yaml:"subject"`
+	Resource  prm.SimpleResource `yaml:"resource"`
+	Action    string             `yaml:"action"`
+	Allow     bool               `yaml:"allow"`
+	Reason    string             `yaml:"reason,omitempty"`
+	MatchedBy string             `yaml:"matched_by,omitempty"`
+	Invariant []string           `yaml:"invariant,omitempty"`
+}
+
+type Table struct {
+	Policy string `yaml:"policy"`
+	Cases  []Case `yaml:"cases"`
+}
+
+func LoadTable(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read decision table %s: %w", path, err)
+	}
+
+	var table Table
+	if err := yaml.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("failed to parse decision table %s: %w", path, err)
+	}
+	for i, c := range table.Cases {
+		if c.Name == "" {
+			return nil, fmt.Errorf("decision table %s: case %d has no name", path, i)
+		}
+	}
+	return &table, nil
+}
+
+func (c Case) evaluate(ctx context.Context, policy prm.Policy) prm.Decision {
+	return policy.Evaluate(ctx, c.Subject, c.Resource, prm.SimpleAction{Name: c.Action})
+}
+
+func (c Case) check(decision prm.Decision) error {
+	if decision.Allow != c.Allow {
+		return fmt.Errorf("expected allow=%v, got allow=%v (reason %q)", c.Allow, decision.Allow, decision.Reason)
+	}
+	if c.Reason != "" && decision.Reason != c.Reason {
+		return fmt.Errorf("expected reason %q, got %q", c.Reason, decision.Reason)
+	}
+	if c.MatchedBy != "" && decision.MatchedBy != c.MatchedBy {
+		return fmt.Errorf("expected matched_by %q, got %q", c.MatchedBy, decision.MatchedBy)
+	}
+	return nil
+}
+
+// Run evaluates every case as a subtest and logs which rules the table
+// exercised. The returned Coverage is nil for policy types Instrument does
+// not understand.
+func Run(t *testing.T, policy prm.Policy, table *Table) *Coverage {
+	t.Helper()
+	instrumented, coverage := Instrument(policy)
+
+	for _, c := range table.Cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if err := c.check(c.evaluate(t.Context(), instrumented)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	if coverage != nil {
+		t.Log(coverage.Report())
+	}
+	return coverage
+}
@@ -0,0 +1,176 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+type Permission struct {
+	ResourceType string
+	Action       string
+}
+
+// RoleBinding grants Role to a subject, either for every resource (empty
+// ResourceType and ResourceID), for every resource of a type, or for a
+// single resource instance.
+type RoleBinding struct {
+	SubjectID    string
+	Role         string
+	ResourceType string
+	ResourceID   string
+}
+
+func (b RoleBinding) appliesTo(resource Resource) bool {
+	if b.ResourceType != "" && b.ResourceType != resource.GetType() {
+		return false
+	}
+	return b.ResourceID == "" || b.ResourceID == resource.GetID()
+}
+
+// RBAC holds roles, their inheritance and bindings. A role inherits every
+// permission of the roles it includes, so with admin ⊇ editor ⊇ viewer an
+// admin binding grants viewer permissions as well.
+type RBAC struct {
+	mu          sync.RWMutex
+	permissions map[string][]Permission
+	includes    map[string][]string
+	bindings    map[string][]RoleBinding
+}
+
+func NewRBAC() *RBAC {
+	return &RBAC{
+		permissions: map[string][]Permission{},
+		includes:    map[string][]string{},
+		bindings:    map[string][]RoleBinding{},
+	}
+}
+
+// AddRole declares role with its own permissions and the roles it includes.
+// Including a role that would create a cycle is an error.
+func (r *RBAC) AddRole(role string, includes []string, permissions ...Permission) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, inc := range includes {
+		if inc == role || slices.Contains(r.expand(inc), role) {
+			return fmt.Errorf("role %s: including %s creates a cycle", role, inc)
+		}
+	}
+	r.permissions[role] = permissions
+	r.includes[role] = includes
+	return nil
+}
+
+func (r *RBAC) Bind(binding RoleBinding) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bindings[binding.SubjectID] = append(r.bindings[binding.SubjectID], binding)
+}
+
+func (r *RBAC) Unbind(binding RoleBinding) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bindings[binding.SubjectID] = slices.DeleteFunc(r.bindings[binding.SubjectID], func(b RoleBinding) bool {
+		return b == binding
+	})
+}
+
+// HasRole reports whether subject holds role on resource, directly or
+// through a role that includes it.
+func (r *RBAC) HasRole(subjectID, role string, resource Resource) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, b := range r.bindings[subjectID] {
+		if b.appliesTo(resource) && slices.Contains(r.expand(b.Role), role) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAllowed reports whether any role bound to subject for resource grants
+// action on the resource's type.
+func (r *RBAC) IsAllowed(subjectID string, resource Resource, action string) bool {
+	want := Permission{ResourceType: resource.GetType(), Action: action}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, b := range r.bindings[subjectID] {
+		if !b.appliesTo(resource) {
+			continue
+		}
+		for _, role := range r.expand(b.Role) {
+			if slices.Contains(r.permissions[role], want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expand returns role followed by every role it transitively includes.
+// It must be called with mu held.
+func (r *RBAC) expand(role string) []string {
+	roles := []string{role}
+	for i := 0; i < len(roles); i++ {
+		for _, inc := range r.includes[roles[i]] {
+			if !slices.Contains(roles, inc) {
+				roles = append(roles, inc)
+			}
+		}
+	}
+	return roles
+}
+
+// PermissionRule allows a request when the subject's roles grant the action
+// on the resource.
+type PermissionRule struct {
+	ID           string
+	RulePriority int
+	RBAC         *RBAC
+}
+
+func (r *PermissionRule) Matches(_ context.Context, subject Subject, resource Resource, action Action) bool {
+	return r.RBAC.IsAllowed(subject.GetID(), resource, action.GetName())
+}
+
+func (r *PermissionRule) GetID() string {
+	return r.ID
+}
+
+func (*PermissionRule) Effect() Effect {
+	return EffectAllow
+}
+
+func (r *PermissionRule) Priority() int {
+	return r.RulePriority
+}
+
+// RoleRule matches when the subject holds Role on the resource, replacing
+// ad-hoc checks of a "role" attribute. Combine it with AllOfRule for
+// conditions beyond the role.
+type RoleRule struct {
+	ID           string
+	RuleEffect   Effect
+	RulePriority int
+	RBAC         *RBAC
+	Role         string
+}
+
+func (r *RoleRule) Matches(_ context.Context, subject Subject, resource Resource, _ Action) bool {
+	return r.RBAC.HasRole(subject.GetID(), r.Role, resource)
+}
+
+func (r *RoleRule) GetID() string {
+	return r.ID
+}
+
+func (r *RoleRule) Effect() Effect {
+	return r.RuleEffect
+}
+
+func (r *RoleRule) Priority() int {
+	return r.RulePriority
+}
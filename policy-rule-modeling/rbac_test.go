@@ -0,0 +1,81 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"testing"
+)
+
+func newDocumentRBAC(t *testing.T) *RBAC {
+	t.Helper()
+	rbac := NewRBAC()
+	mustAddRole := func(role string, includes []string, permissions ...Permission) {
+		if err := rbac.AddRole(role, includes, permissions...); err != nil {
+			t.Fatalf("Expected role %s to be added, got %v", role, err)
+		}
+	}
+	mustAddRole("viewer", nil, Permission{"document", "read"})
+	mustAddRole("editor", []string{"viewer"}, Permission{"document", "write"})
+	mustAddRole("admin", []string{"editor"}, Permission{"document", "delete"})
+	return rbac
+}
+
+func TestRBAC(t *testing.T) {
+	ctx := context.Background()
+	rbac := newDocumentRBAC(t)
+	rbac.Bind(RoleBinding{SubjectID: "alice", Role: "admin"})
+	rbac.Bind(RoleBinding{SubjectID: "bob", Role: "editor", ResourceType: "document", ResourceID: "doc1"})
+	rbac.Bind(RoleBinding{SubjectID: "carol", Role: "viewer", ResourceType: "document"})
+
+	policy := &SimplePolicy{
+		ID:    "rbac-policy",
+		Name:  "RBAC Policy",
+		Rules: []Rule{&PermissionRule{ID: "rbac", RBAC: rbac}},
+	}
+	doc1 := SimpleResource{Type: "document", ID: "doc1"}
+	doc2 := SimpleResource{Type: "document", ID: "doc2"}
+
+	tests := []struct {
+		name     string
+		subject  string
+		resource Resource
+		action   string
+		allow    bool
+	}{
+		{"admin inherits viewer permission", "alice", doc2, "read", true},
+		{"admin deletes", "alice", doc1, "delete", true},
+		{"editor writes bound document", "bob", doc1, "write", true},
+		{"editor cannot write other document", "bob", doc2, "write", false},
+		{"editor cannot delete", "bob", doc1, "delete", false},
+		{"type-scoped viewer reads any document", "carol", doc2, "read", true},
+		{"unbound subject denied", "dave", doc1, "read", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := policy.Evaluate(ctx, SimpleSubject{ID: tt.subject}, tt.resource, SimpleAction{Name: tt.action})
+			if decision.Allow != tt.allow {
+				t.Errorf("Expected Allow=%v, got %+v", tt.allow, decision)
+			}
+		})
+	}
+
+	t.Run("role rule sees inherited roles", func(t *testing.T) {
+		rule := &RoleRule{ID: "is-viewer", RuleEffect: EffectAllow, RBAC: rbac, Role: "viewer"}
+		if !rule.Matches(ctx, SimpleSubject{ID: "alice"}, doc1, SimpleAction{Name: "read"}) {
+			t.Errorf("Expected admin to hold viewer role")
+		}
+	})
+
+	t.Run("unbind revokes role", func(t *testing.T) {
+		rbac.Unbind(RoleBinding{SubjectID: "carol", Role: "viewer", ResourceType: "document"})
+		if rbac.IsAllowed("carol", doc1, "read") {
+			t.Errorf("Expected carol to lose read access")
+		}
+	})
+
+	t.Run("rejects inheritance cycles", func(t *testing.T) {
+		if err := rbac.AddRole("viewer", []string{"admin"}); err == nil {
+			t.Errorf("Expected cycle error")
+		}
+	})
+}
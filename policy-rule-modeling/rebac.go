@@ -0,0 +1,149 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrMaxDepth = errors.New("relation check exceeded max depth")
+
+// TupleToUserset follows Tupleset on the object to other objects and checks
+// Relation there, e.g. Tupleset "parent", Relation "viewer" grants viewers of
+// the parent folder.
+type TupleToUserset struct {
+	Tupleset string
+	Relation string
+}
+
+// RelationRewrite defines who has a relation: subjects of direct tuples
+// (unless NoDirect), holders of the Computed relations on the same object,
+// and subjects found through each TupleToUserset.
+type RelationRewrite struct {
+	NoDirect bool
+	Computed []string
+	Parents  []TupleToUserset
+}
+
+// Schema maps object type and relation to its rewrite. Relations without an
+// entry are direct only.
+type Schema map[string]map[string]RelationRewrite
+
+const defaultMaxDepth = 25
+
+type Checker struct {
+	Store    TupleStore
+	Schema   Schema
+	MaxDepth int
+}
+
+// Check reports whether subject has relation on object.
+func (c *Checker) Check(ctx context.Context, object ObjectRef, relation string, subject ObjectRef) (bool, error) {
+	maxDepth := c.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxDepth
+	}
+	return c.check(ctx, object, relation, subject, maxDepth)
+}
+
+func (c *Checker) check(ctx context.Context, object ObjectRef, relation string, subject ObjectRef, depth int) (bool, error) {
+	if depth == 0 {
+		return false, fmt.Errorf("%w checking %s#%s", ErrMaxDepth, object, relation)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	rewrite := c.Schema[object.Type][relation]
+
+	if !rewrite.NoDirect {
+		if ok, err := c.checkDirect(ctx, object, relation, subject, depth); ok || err != nil {
+			return ok, err
+		}
+	}
+
+	for _, computed := range rewrite.Computed {
+		if ok, err := c.check(ctx, object, computed, subject, depth-1); ok || err != nil {
+			return ok, err
+		}
+	}
+
+	for _, ttu := range rewrite.Parents {
+		tuples, err := c.Store.Read(ctx, object, ttu.Tupleset)
+		if err != nil {
+			return false, err
+		}
+		for _, t := range tuples {
+			if ok, err := c.check(ctx, t.Subject.Object, ttu.Relation, subject, depth-1); ok || err != nil {
+				return ok, err
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (c *Checker) checkDirect(ctx context.Context, object ObjectRef, relation string, subject ObjectRef, depth int) (bool, error) {
+	tuples, err := c.Store.Read(ctx, object, relation)
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range tuples {
+		if t.Subject.Relation == "" && t.Subject.Object == subject {
+			return true, nil
+		}
+	}
+	for _, t := range tuples {
+		if t.Subject.Relation == "" {
+			continue
+		}
+		if ok, err := c.check(ctx, t.Subject.Object, t.Subject.Relation, subject, depth-1); ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// RelationRule matches when the subject, as an object of SubjectType, has the
+// relation mapped from the action on the resource. Actions missing from
+// Relations are used as the relation name. Check errors fail closed: a deny
+// rule matches when the check fails, an allow rule does not.
+type RelationRule struct {
+	ID           string
+	RuleEffect   Effect
+	RulePriority int
+	Checker      *Checker
+	SubjectType  string
+	Relations    map[string]string
+}
+
+func (r *RelationRule) Matches(ctx context.Context, subject Subject, resource Resource, action Action) bool {
+	relation, ok := r.Relations[action.GetName()]
+	if !ok {
+		relation = action.GetName()
+	}
+
+	allowed, err := r.Checker.Check(
+		ctx,
+		ObjectRef{Type: resource.GetType(), ID: resource.GetID()},
+		relation,
+		ObjectRef{Type: r.SubjectType, ID: subject.GetID()},
+	)
+	if err != nil {
+		return r.RuleEffect == EffectDeny
+	}
+	return allowed
+}
+
+func (r *RelationRule) GetID() string {
+	return r.ID
+}
+
+func (r *RelationRule) Effect() Effect {
+	return r.RuleEffect
+}
+
+func (r *RelationRule) Priority() int {
+	return r.RulePriority
+}
@@ -0,0 +1,206 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var documentSchema = Schema{
+	"document": {
+		"viewer": {Computed: []string{"editor"}, Parents: []TupleToUserset{{Tupleset: "parent", Relation: "viewer"}}},
+		"editor": {Computed: []string{"owner"}},
+	},
+	"folder": {
+		"viewer": {Computed: []string{"owner"}},
+	},
+}
+
+func mustParseTuples(t *testing.T, lines ...string) []RelationTuple {
+	t.Helper()
+	tuples := make([]RelationTuple, len(lines))
+	for i, line := range lines {
+		tuple, err := ParseTuple(line)
+		if err != nil {
+			t.Fatalf("Expected tuple to parse, got %v", err)
+		}
+		tuples[i] = tuple
+	}
+	return tuples
+}
+
+func TestChecker_Check(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTupleStore()
+	_ = store.Write(ctx, mustParseTuples(t,
+		"document:doc1#owner@user:alice",
+		"document:doc1#parent@folder:shared",
+		"folder:shared#viewer@group:eng#member",
+		"group:eng#member@user:bob",
+		"document:doc2#editor@user:carol",
+	)...)
+	checker := &Checker{Store: store, Schema: documentSchema}
+
+	tests := []struct {
+		name     string
+		object   ObjectRef
+		relation string
+		user     string
+		want     bool
+	}{
+		{"owner is viewer through editor", ObjectRef{"document", "doc1"}, "viewer", "alice", true},
+		{"group member views through parent folder", ObjectRef{"document", "doc1"}, "viewer", "bob", true},
+		{"group member is not editor", ObjectRef{"document", "doc1"}, "editor", "bob", false},
+		{"editor is viewer", ObjectRef{"document", "doc2"}, "viewer", "carol", true},
+		{"unrelated user", ObjectRef{"document", "doc2"}, "viewer", "bob", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checker.Check(ctx, tt.object, tt.relation, ObjectRef{"user", tt.user})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("stops at max depth", func(t *testing.T) {
+		cyclic := NewMemoryTupleStore()
+		_ = cyclic.Write(ctx, mustParseTuples(t,
+			"group:a#member@group:b#member",
+			"group:b#member@group:a#member",
+		)...)
+		c := &Checker{Store: cyclic, MaxDepth: 5}
+		if _, err := c.Check(ctx, ObjectRef{"group", "a"}, "member", ObjectRef{"user", "alice"}); !errors.Is(err, ErrMaxDepth) {
+			t.Errorf("Expected ErrMaxDepth, got %v", err)
+		}
+	})
+}
+
+func TestRelationRule(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTupleStore()
+	_ = store.Write(ctx, mustParseTuples(t, "document:doc1#owner@user:alice", "document:doc1#viewer@user:bob")...)
+
+	policy := &SimplePolicy{
+		ID:   "rebac-policy",
+		Name: "ReBAC Policy",
+		Rules: []Rule{&RelationRule{
+			ID:          "relations",
+			RuleEffect:  EffectAllow,
+			Checker:     &Checker{Store: store, Schema: documentSchema},
+			SubjectType: "user",
+			Relations:   map[string]string{"read": "viewer", "write": "editor"},
+		}},
+	}
+	doc := SimpleResource{Type: "document", ID: "doc1"}
+
+	if !policy.Evaluate(ctx, SimpleSubject{ID: "alice"}, doc, SimpleAction{Name: "write"}).Allow {
+		t.Errorf("Expected owner to write")
+	}
+	if policy.Evaluate(ctx, SimpleSubject{ID: "bob"}, doc, SimpleAction{Name: "write"}).Allow {
+		t.Errorf("Expected viewer not to write")
+	}
+	if !policy.Evaluate(ctx, SimpleSubject{ID: "bob"}, doc, SimpleAction{Name: "read"}).Allow {
+		t.Errorf("Expected viewer to read")
+	}
+
+	t.Run("fails closed on check errors", func(t *testing.T) {
+		failing := &Checker{Store: failingTupleStore{TupleStore: store}}
+		deny := &SimplePolicy{
+			ID:   "rebac-deny",
+			Name: "ReBAC Deny",
+			Rules: []Rule{
+				&RelationRule{ID: "blocked", RuleEffect: EffectDeny, Checker: failing, SubjectType: "user", Relations: map[string]string{"read": "blocked"}},
+				&RelationRule{ID: "viewers", RuleEffect: EffectAllow, Checker: &Checker{Store: store, Schema: documentSchema}, SubjectType: "user", Relations: map[string]string{"read": "viewer"}},
+			},
+		}
+		decision := deny.Evaluate(ctx, SimpleSubject{ID: "bob"}, doc, SimpleAction{Name: "read"})
+		if decision.Allow || decision.RuleID != "blocked" {
+			t.Errorf("Expected deny by blocked, got %+v", decision)
+		}
+
+		allow := &RelationRule{ID: "relations", RuleEffect: EffectAllow, Checker: failing, SubjectType: "user"}
+		if allow.Matches(ctx, SimpleSubject{ID: "alice"}, doc, SimpleAction{Name: "owner"}) {
+			t.Errorf("Expected allow rule not to match on check errors")
+		}
+	})
+}
+
+// failingTupleStore fails every read.
+type failingTupleStore struct {
+	TupleStore
+}
+
+func (failingTupleStore) Read(context.Context, ObjectRef, string) ([]RelationTuple, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func TestFileTupleStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tuples.txt")
+	tuples := mustParseTuples(t, "document:doc1#owner@user:alice", "folder:shared#viewer@group:eng#member")
+
+	store, err := NewFileTupleStore(path)
+	if err != nil {
+		t.Fatalf("Expected store, got %v", err)
+	}
+	if err := store.Write(ctx, tuples...); err != nil {
+		t.Fatalf("Expected write to succeed, got %v", err)
+	}
+	if err := store.Delete(ctx, tuples[0]); err != nil {
+		t.Fatalf("Expected delete to succeed, got %v", err)
+	}
+
+	reopened, err := NewFileTupleStore(path)
+	if err != nil {
+		t.Fatalf("Expected store to reopen, got %v", err)
+	}
+	got, _ := reopened.Read(ctx, ObjectRef{"folder", "shared"}, "viewer")
+	if len(got) != 1 || got[0] != tuples[1] {
+		t.Errorf("Expected persisted tuple %v, got %v", tuples[1], got)
+	}
+	if got, _ := reopened.Read(ctx, ObjectRef{"document", "doc1"}, "owner"); len(got) != 0 {
+		t.Errorf("Expected deleted tuple to stay deleted, got %v", got)
+	}
+}
+
+func TestFileTupleStore_FailedFlush(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "tuples")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tuples := mustParseTuples(t, "document:doc1#owner@user:alice", "document:doc1#viewer@user:bob")
+
+	store, err := NewFileTupleStore(filepath.Join(dir, "tuples.txt"))
+	if err != nil {
+		t.Fatalf("Expected store, got %v", err)
+	}
+	if err := store.Write(ctx, tuples[0]); err != nil {
+		t.Fatalf("Expected write to succeed, got %v", err)
+	}
+	// without its directory the file can no longer be rewritten
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Write(ctx, tuples[1]); err == nil {
+		t.Fatalf("Expected write to fail")
+	}
+	if err := store.Delete(ctx, tuples[0]); err == nil {
+		t.Fatalf("Expected delete to fail")
+	}
+
+	if got, _ := store.Read(ctx, ObjectRef{"document", "doc1"}, "viewer"); len(got) != 0 {
+		t.Errorf("Expected failed write not to be applied, got %v", got)
+	}
+	if got, _ := store.Read(ctx, ObjectRef{"document", "doc1"}, "owner"); len(got) != 1 {
+		t.Errorf("Expected failed delete not to be applied, got %v", got)
+	}
+}
@@ -0,0 +1,236 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownVersion = errors.New("unknown policy version")
+	ErrNoShadow       = errors.New("no shadow policy version")
+)
+
+type PolicyVersion struct {
+	Version   int
+	Policy    Policy
+	Note      string
+	CreatedAt time.Time
+}
+
+// Divergence records a request for which the shadow policy decided
+// differently from the active one.
+type Divergence struct {
+	At            time.Time
+	ActiveVersion int
+	ShadowVersion int
+	SubjectID     string
+	ResourceType  string
+	ResourceID    string
+	Action        string
+	Active        Decision
+	Shadow        Decision
+}
+
+type DivergenceRecorder interface {
+	Record(d Divergence)
+}
+
+// DivergenceLog keeps the most recent divergences in memory.
+type DivergenceLog struct {
+	mu      sync.Mutex
+	limit   int
+	total   int
+	entries []Divergence
+}
+
+func NewDivergenceLog(limit int) *DivergenceLog {
+	return &DivergenceLog{limit: limit}
+}
+
+func (l *DivergenceLog) Record(d Divergence) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total++
+	l.entries = append(l.entries, d)
+	if l.limit > 0 && len(l.entries) > l.limit {
+		l.entries = l.entries[len(l.entries)-l.limit:]
+	}
+}
+
+func (l *DivergenceLog) Entries() []Divergence {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Divergence(nil), l.entries...)
+}
+
+// Total counts all divergences, including those no longer kept.
+func (l *DivergenceLog) Total() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total
+}
+
+func (l *DivergenceLog) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total = 0
+	l.entries = nil
+}
+
+// PolicyStore keeps every version of a policy. It implements Policy by
+// enforcing the active version; when a shadow version is set it is evaluated
+// too and differing decisions are recorded, but never enforced.
+type PolicyStore struct {
+	id       string
+	name     string
+	clock    Clock
+	recorder DivergenceRecorder
+
+	mu       sync.RWMutex
+	versions []PolicyVersion
+	active   int
+	shadow   int
+}
+
+func NewPolicyStore(id, name string, recorder DivergenceRecorder, clock Clock) *PolicyStore {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	return &PolicyStore{id: id, name: name, recorder: recorder, clock: clock}
+}
+
+// Add stores policy as a new version and returns its number. The first
+// version added becomes active.
+func (s *PolicyStore) Add(policy Policy, note string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	version := len(s.versions) + 1
+	s.versions = append(s.versions, PolicyVersion{Version: version, Policy: policy, Note: note, CreatedAt: s.clock.Now()})
+	if s.active == 0 {
+		s.active = version
+	}
+	return version
+}
+
+func (s *PolicyStore) Versions() []PolicyVersion {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]PolicyVersion(nil), s.versions...)
+}
+
+func (s *PolicyStore) Activate(version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkVersion(version); err != nil {
+		return err
+	}
+	s.active = version
+	if s.shadow == version {
+		s.shadow = 0
+	}
+	return nil
+}
+
+func (s *PolicyStore) SetShadow(version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkVersion(version); err != nil {
+		return err
+	}
+	s.shadow = version
+	return nil
+}
+
+func (s *PolicyStore) ClearShadow() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shadow = 0
+}
+
+// Promote makes the shadow version active.
+func (s *PolicyStore) Promote() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shadow == 0 {
+		return ErrNoShadow
+	}
+	s.active, s.shadow = s.shadow, 0
+	return nil
+}
+
+// Active and Shadow return the version numbers in use, 0 meaning none.
+func (s *PolicyStore) Active() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+func (s *PolicyStore) Shadow() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shadow
+}
+
+func (s *PolicyStore) checkVersion(version int) error {
+	if version < 1 || version > len(s.versions) {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return nil
+}
+
+func (s *PolicyStore) Evaluate(ctx context.Context, subject Subject, resource Resource, action Action) Decision {
+	s.mu.RLock()
+	active, shadow := s.active, s.shadow
+	var activePolicy, shadowPolicy Policy
+	if active > 0 {
+		activePolicy = s.versions[active-1].Policy
+	}
+	if shadow > 0 {
+		shadowPolicy = s.versions[shadow-1].Policy
+	}
+	s.mu.RUnlock()
+
+	if activePolicy == nil {
+		return Decision{Allow: false, Reason: "no active policy version"}
+	}
+
+	decision := activePolicy.Evaluate(ctx, subject, resource, action)
+	if shadowPolicy == nil || s.recorder == nil {
+		return decision
+	}
+
+	shadowDecision := shadowPolicy.Evaluate(ctx, subject, resource, action)
+	if diverges(decision, shadowDecision) {
+		s.recorder.Record(Divergence{
+			At:            s.clock.Now(),
+			ActiveVersion: active,
+			ShadowVersion: shadow,
+			SubjectID:     subject.GetID(),
+			ResourceType:  resource.GetType(),
+			ResourceID:    resource.GetID(),
+			Action:        action.GetName(),
+			Active:        decision,
+			Shadow:        shadowDecision,
+		})
+	}
+	return decision
+}
+
+// diverges ignores Reason and MatchedBy, which change with every rename.
+func diverges(a, b Decision) bool {
+	return a.Allow != b.Allow ||
+		!reflect.DeepEqual(a.Obligations, b.Obligations) ||
+		!reflect.DeepEqual(a.Advice, b.Advice)
+}
+
+func (s *PolicyStore) GetID() string {
+	return s.id
+}
+
+func (s *PolicyStore) GetName() string {
+	return s.name
+}
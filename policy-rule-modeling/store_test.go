@@ -0,0 +1,69 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPolicyStore(t *testing.T) {
+	ctx := context.Background()
+	viewer := SimpleSubject{ID: "user456", Attributes: map[string]interface{}{"role": "viewer"}}
+	doc := SimpleResource{Type: "document", ID: "doc1"}
+	read := SimpleAction{Name: "read"}
+	del := SimpleAction{Name: "delete"}
+
+	current := &SimplePolicy{ID: "documents", Name: "Documents v1", Rules: []Rule{
+		&AttributeRule{ID: "viewer-read", RuleEffect: EffectAllow, Actions: []string{"read"}},
+	}}
+	candidate := &SimplePolicy{ID: "documents", Name: "Documents v2", Rules: []Rule{
+		&AttributeRule{ID: "viewer-any", RuleEffect: EffectAllow},
+	}}
+
+	log := NewDivergenceLog(10)
+	store := NewPolicyStore("documents", "Documents", log, nil)
+	v1 := store.Add(current, "initial")
+	v2 := store.Add(candidate, "allow everything")
+
+	if err := store.SetShadow(v2); err != nil {
+		t.Fatalf("Expected shadow to be set, got %v", err)
+	}
+
+	t.Run("enforces active version only", func(t *testing.T) {
+		if store.Evaluate(ctx, viewer, doc, del).Allow {
+			t.Errorf("Expected active version %d to deny delete", v1)
+		}
+	})
+
+	t.Run("records divergences", func(t *testing.T) {
+		store.Evaluate(ctx, viewer, doc, read)
+		entries := log.Entries()
+		if log.Total() != 1 || len(entries) != 1 {
+			t.Fatalf("Expected exactly 1 divergence, got %d", log.Total())
+		}
+		if d := entries[0]; d.Action != "delete" || d.Active.Allow || !d.Shadow.Allow || d.ShadowVersion != v2 {
+			t.Errorf("Expected delete divergence between v1 and v2, got %+v", d)
+		}
+	})
+
+	t.Run("promotes shadow", func(t *testing.T) {
+		if err := store.Promote(); err != nil {
+			t.Fatalf("Expected promote to succeed, got %v", err)
+		}
+		if store.Active() != v2 || store.Shadow() != 0 {
+			t.Errorf("Expected v2 active without shadow, got active=%d shadow=%d", store.Active(), store.Shadow())
+		}
+		if !store.Evaluate(ctx, viewer, doc, del).Allow {
+			t.Errorf("Expected promoted version to allow delete")
+		}
+		if err := store.Promote(); !errors.Is(err, ErrNoShadow) {
+			t.Errorf("Expected ErrNoShadow, got %v", err)
+		}
+	})
+
+	t.Run("rejects unknown version", func(t *testing.T) {
+		if err := store.Activate(3); !errors.Is(err, ErrUnknownVersion) {
+			t.Errorf("Expected ErrUnknownVersion, got %v", err)
+		}
+	})
+}
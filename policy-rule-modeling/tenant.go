@@ -0,0 +1,160 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrNoTenant            = errors.New("no tenant in context")
+	ErrTenantPolicyMissing = errors.New("tenant has no such policy")
+)
+
+// TenantAttribute names the subject and resource attribute that records the
+// tenant owning them.
+const TenantAttribute = "tenant"
+
+// TenantRegistry keeps a separate policy namespace per tenant. Lookups take
+// the tenant from the Environment in the context, so one tenant can never
+// reach another's policies by ID.
+type TenantRegistry struct {
+	decorators []func(Policy) Policy
+
+	mu      sync.RWMutex
+	tenants map[string]map[string]Policy
+}
+
+// NewTenantRegistry wraps every added policy in decorators, innermost first.
+func NewTenantRegistry(decorators ...func(Policy) Policy) *TenantRegistry {
+	return &TenantRegistry{decorators: decorators, tenants: map[string]map[string]Policy{}}
+}
+
+// Add stores policy under its ID for tenant, replacing any policy with the
+// same ID.
+func (r *TenantRegistry) Add(tenant string, policy Policy) error {
+	if tenant == "" {
+		return ErrNoTenant
+	}
+	for _, decorate := range r.decorators {
+		policy = decorate(policy)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tenants[tenant] == nil {
+		r.tenants[tenant] = map[string]Policy{}
+	}
+	r.tenants[tenant][policy.GetID()] = policy
+	return nil
+}
+
+// Load replaces all of tenant's policies with those in bundle.
+func (r *TenantRegistry) Load(tenant string, bundle *Bundle) error {
+	if tenant == "" {
+		return ErrNoTenant
+	}
+	policies := bundle.Build()
+	for id, p := range policies {
+		for _, decorate := range r.decorators {
+			p = decorate(p)
+		}
+		policies[id] = p
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tenants[tenant] = policies
+	return nil
+}
+
+func (r *TenantRegistry) Remove(tenant, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tenants[tenant][id]; !ok {
+		return fmt.Errorf("%w: %s/%s", ErrTenantPolicyMissing, tenant, id)
+	}
+	delete(r.tenants[tenant], id)
+	if len(r.tenants[tenant]) == 0 {
+		delete(r.tenants, tenant)
+	}
+	return nil
+}
+
+func (r *TenantRegistry) Tenants() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tenants := make([]string, 0, len(r.tenants))
+	for t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// IDs returns tenant's policy IDs.
+func (r *TenantRegistry) IDs(tenant string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.tenants[tenant]))
+	for id := range r.tenants[tenant] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Get returns the policy id of the tenant in ctx.
+func (r *TenantRegistry) Get(ctx context.Context, id string) (Policy, error) {
+	tenant := EnvironmentFromContext(ctx).Tenant
+	if tenant == "" {
+		return nil, ErrNoTenant
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.tenants[tenant][id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s/%s", ErrTenantPolicyMissing, tenant, id)
+	}
+	return p, nil
+}
+
+// Policy returns a Policy that evaluates id in whichever tenant the request
+// belongs to. It denies requests without a tenant, for tenants lacking the
+// policy, and whenever the subject or resource carries a TenantAttribute
+// naming another tenant.
+func (r *TenantRegistry) Policy(id string) Policy {
+	return &tenantPolicy{registry: r, id: id}
+}
+
+type tenantPolicy struct {
+	registry *TenantRegistry
+	id       string
+}
+
+func (p *tenantPolicy) Evaluate(ctx context.Context, subject Subject, resource Resource, action Action) Decision {
+	policy, err := p.registry.Get(ctx, p.id)
+	if err != nil {
+		return Decision{Allow: false, Reason: err.Error()}
+	}
+
+	tenant := EnvironmentFromContext(ctx).Tenant
+	if owner, ok := subject.GetAttributes()[TenantAttribute]; ok && owner != tenant {
+		return Decision{Allow: false, Reason: fmt.Sprintf("cross-tenant access: subject belongs to %v, request to %s", owner, tenant)}
+	}
+	if owner, ok := resource.GetAttributes()[TenantAttribute]; ok && owner != tenant {
+		return Decision{Allow: false, Reason: fmt.Sprintf("cross-tenant access: resource belongs to %v, request to %s", owner, tenant)}
+	}
+	return policy.Evaluate(ctx, subject, resource, action)
+}
+
+func (p *tenantPolicy) GetID() string {
+	return p.id
+}
+
+func (p *tenantPolicy) GetName() string {
+	return p.id
+}
@@ -0,0 +1,62 @@
+package policyrulemodeling
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTenantRegistry(t *testing.T) {
+	reg := NewTenantRegistry()
+	allowAll := &SimplePolicy{ID: "documents", Name: "Allow All", Rules: []Rule{&AttributeRule{ID: "all", RuleEffect: EffectAllow}}}
+	if err := reg.Add("acme", allowAll); err != nil {
+		t.Fatalf("Expected add to succeed, got %v", err)
+	}
+	if err := reg.Add("", allowAll); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("Expected ErrNoTenant, got %v", err)
+	}
+
+	policy := reg.Policy("documents")
+	alice := SimpleSubject{ID: "alice", Attributes: map[string]interface{}{"tenant": "acme"}}
+	read := SimpleAction{Name: "read"}
+	inTenant := func(tenant string) context.Context {
+		return ContextWithEnvironment(context.Background(), Environment{Tenant: tenant})
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		subject  Subject
+		resource Resource
+		allow    bool
+	}{
+		{"allows within tenant", inTenant("acme"), alice, SimpleResource{Type: "document", ID: "doc1", Attributes: map[string]interface{}{"tenant": "acme"}}, true},
+		{"denies without tenant", context.Background(), alice, SimpleResource{Type: "document", ID: "doc1"}, false},
+		{"denies tenant without policy", inTenant("globex"), SimpleSubject{ID: "bob"}, SimpleResource{Type: "document", ID: "doc1"}, false},
+		{"denies cross-tenant resource", inTenant("acme"), alice, SimpleResource{Type: "document", ID: "doc2", Attributes: map[string]interface{}{"tenant": "globex"}}, false},
+		{"denies cross-tenant subject", inTenant("acme"), SimpleSubject{ID: "bob", Attributes: map[string]interface{}{"tenant": "globex"}}, SimpleResource{Type: "document", ID: "doc1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := policy.Evaluate(tt.ctx, tt.subject, tt.resource, read)
+			if decision.Allow != tt.allow {
+				t.Errorf("Expected allow %v, got %+v", tt.allow, decision)
+			}
+		})
+	}
+
+	if got := reg.IDs("acme"); !reflect.DeepEqual(got, []string{"documents"}) {
+		t.Errorf("Expected acme to have [documents], got %v", got)
+	}
+	if err := reg.Remove("globex", "documents"); !errors.Is(err, ErrTenantPolicyMissing) {
+		t.Errorf("Expected removing another tenant's policy to fail, got %v", err)
+	}
+	if err := reg.Remove("acme", "documents"); err != nil {
+		t.Fatalf("Expected remove to succeed, got %v", err)
+	}
+	if got := reg.Tenants(); len(got) != 0 {
+		t.Errorf("Expected no tenants left, got %v", got)
+	}
+}
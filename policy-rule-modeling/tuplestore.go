@@ -0,0 +1,238 @@
+package policyrulemodeling
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type ObjectRef struct {
+	Type string
+	ID   string
+}
+
+func (o ObjectRef) String() string {
+	return o.Type + ":" + o.ID
+}
+
+// SubjectRef is either an object such as user:alice, or a userset such as
+// group:eng#member meaning every subject with that relation on the object.
+type SubjectRef struct {
+	Object   ObjectRef
+	Relation string
+}
+
+func (s SubjectRef) String() string {
+	if s.Relation == "" {
+		return s.Object.String()
+	}
+	return s.Object.String() + "#" + s.Relation
+}
+
+// RelationTuple states that Subject has Relation on Object, written as
+// object#relation@subject, e.g. document:doc1#viewer@user:alice.
+type RelationTuple struct {
+	Object   ObjectRef
+	Relation string
+	Subject  SubjectRef
+}
+
+func (t RelationTuple) String() string {
+	return t.Object.String() + "#" + t.Relation + "@" + t.Subject.String()
+}
+
+func ParseTuple(s string) (RelationTuple, error) {
+	objectRel, subject, ok := strings.Cut(s, "@")
+	if !ok {
+		return RelationTuple{}, fmt.Errorf("tuple %q: missing @subject", s)
+	}
+	object, relation, ok := strings.Cut(objectRel, "#")
+	if !ok || relation == "" {
+		return RelationTuple{}, fmt.Errorf("tuple %q: missing #relation", s)
+	}
+
+	o, err := parseObjectRef(object)
+	if err != nil {
+		return RelationTuple{}, fmt.Errorf("tuple %q: %w", s, err)
+	}
+	subjectObject, subjectRelation, _ := strings.Cut(subject, "#")
+	so, err := parseObjectRef(subjectObject)
+	if err != nil {
+		return RelationTuple{}, fmt.Errorf("tuple %q: %w", s, err)
+	}
+
+	return RelationTuple{Object: o, Relation: relation, Subject: SubjectRef{Object: so, Relation: subjectRelation}}, nil
+}
+
+func parseObjectRef(s string) (ObjectRef, error) {
+	typ, id, ok := strings.Cut(s, ":")
+	if !ok || typ == "" || id == "" {
+		return ObjectRef{}, fmt.Errorf("invalid object %q, want type:id", s)
+	}
+	return ObjectRef{Type: typ, ID: id}, nil
+}
+
+type TupleStore interface {
+	Write(ctx context.Context, tuples ...RelationTuple) error
+	Delete(ctx context.Context, tuples ...RelationTuple) error
+	// Read returns the tuples for object and relation.
+	Read(ctx context.Context, object ObjectRef, relation string) ([]RelationTuple, error)
+}
+
+type objectRelation struct {
+	object   ObjectRef
+	relation string
+}
+
+type MemoryTupleStore struct {
+	mu     sync.RWMutex
+	tuples map[objectRelation][]RelationTuple
+}
+
+func NewMemoryTupleStore() *MemoryTupleStore {
+	return &MemoryTupleStore{tuples: map[objectRelation][]RelationTuple{}}
+}
+
+func (s *MemoryTupleStore) Write(_ context.Context, tuples ...RelationTuple) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range tuples {
+		key := objectRelation{t.Object, t.Relation}
+		if !slices.Contains(s.tuples[key], t) {
+			s.tuples[key] = append(s.tuples[key], t)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTupleStore) Delete(_ context.Context, tuples ...RelationTuple) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range tuples {
+		key := objectRelation{t.Object, t.Relation}
+		s.tuples[key] = slices.DeleteFunc(s.tuples[key], func(have RelationTuple) bool { return have == t })
+		if len(s.tuples[key]) == 0 {
+			delete(s.tuples, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTupleStore) Read(_ context.Context, object ObjectRef, relation string) ([]RelationTuple, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.tuples[objectRelation{object, relation}]), nil
+}
+
+func (s *MemoryTupleStore) all() []RelationTuple {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var tuples []RelationTuple
+	for _, ts := range s.tuples {
+		tuples = append(tuples, ts...)
+	}
+	sort.Slice(tuples, func(i, j int) bool { return tuples[i].String() < tuples[j].String() })
+	return tuples
+}
+
+// FileTupleStore keeps tuples in memory and persists them to a text file with
+// one tuple per line; blank lines and lines starting with # are ignored. The
+// file is rewritten atomically on every change, and a change that cannot be
+// written is not applied in memory either.
+type FileTupleStore struct {
+	path string
+
+	mu  sync.Mutex
+	mem *MemoryTupleStore
+}
+
+func NewFileTupleStore(path string) (*FileTupleStore, error) {
+	s := &FileTupleStore{path: path, mem: NewMemoryTupleStore()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		t, err := ParseTuple(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		_ = s.mem.Write(context.Background(), t)
+	}
+	return s, scanner.Err()
+}
+
+func (s *FileTupleStore) Write(ctx context.Context, tuples ...RelationTuple) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.snapshot()
+	_ = next.Write(ctx, tuples...)
+	if err := s.flush(next); err != nil {
+		return err
+	}
+	return s.mem.Write(ctx, tuples...)
+}
+
+func (s *FileTupleStore) Delete(ctx context.Context, tuples ...RelationTuple) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.snapshot()
+	_ = next.Delete(ctx, tuples...)
+	if err := s.flush(next); err != nil {
+		return err
+	}
+	return s.mem.Delete(ctx, tuples...)
+}
+
+// snapshot copies the tuples so a change can be written before it is applied.
+func (s *FileTupleStore) snapshot() *MemoryTupleStore {
+	next := NewMemoryTupleStore()
+	_ = next.Write(context.Background(), s.mem.all()...)
+	return next
+}
+
+func (s *FileTupleStore) Read(ctx context.Context, object ObjectRef, relation string) ([]RelationTuple, error) {
+	return s.mem.Read(ctx, object, relation)
+}
+
+func (s *FileTupleStore) flush(mem *MemoryTupleStore) error {
+	var buf bytes.Buffer
+	for _, t := range mem.all() {
+		buf.WriteString(t.String())
+		buf.WriteByte('\n')
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}